@@ -0,0 +1,162 @@
+// Package codeblocks extracts fenced code blocks from Markdown text.
+package codeblocks
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Block is a fenced code block.
+type Block struct {
+	// Lang is the language of the block, from its info string; it's
+	// normalized with [NormalizeLang].
+	Lang string
+
+	// Path is the file path hinted in the block's info string, if any. For
+	// example, blocks starting with "```go main.go", "```go:main.go",
+	// "```go title=main.go" or "```main.go" have the path "main.go".
+	Path string
+
+	// Code is the contents of the block.
+	Code string
+}
+
+var fenceRe = regexp.MustCompile("^( {0,3})(```+|~~~+)\\s*(.*)$")
+
+// pathKeys are the keys of key=value attributes in info strings that hold
+// file paths.
+var pathKeys = map[string]bool{"file": true, "filename": true, "path": true, "title": true, "name": true}
+
+// Extract returns the fenced code blocks in the Markdown text md. A block
+// that isn't closed extends to the end of md.
+func Extract(md string) []Block {
+	var blocks []Block
+	var cur *Block
+	var fence string
+	var code strings.Builder
+	for _, line := range strings.Split(md, "\n") {
+		if cur == nil {
+			m := fenceRe.FindStringSubmatch(line)
+			if m == nil || (m[2][0] == '`' && strings.Contains(m[3], "`")) {
+				continue
+			}
+			fence = m[2]
+			cur = parseInfo(m[3])
+			code.Reset()
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			cur.Code = code.String()
+			blocks = append(blocks, *cur)
+			cur = nil
+			continue
+		}
+		code.WriteString(line)
+		code.WriteByte('\n')
+	}
+	if cur != nil {
+		cur.Code = code.String()
+		blocks = append(blocks, *cur)
+	}
+	return blocks
+}
+
+// parseInfo parses the info string of a fenced code block.
+func parseInfo(info string) *Block {
+	b := &Block{}
+	fields := strings.Fields(info)
+	if len(fields) == 0 {
+		return b
+	}
+
+	first := strings.Trim(fields[0], "{}")
+	switch {
+	case strings.Contains(first, ":"):
+		lang, p, _ := strings.Cut(first, ":")
+		b.Lang, b.Path = lang, p
+	case looksLikePath(first):
+		b.Path = first
+		b.Lang = strings.TrimPrefix(path.Ext(first), ".")
+	default:
+		b.Lang = first
+	}
+
+	for _, f := range fields[1:] {
+		if key, value, ok := strings.Cut(f, "="); ok {
+			if pathKeys[strings.ToLower(key)] && b.Path == "" {
+				b.Path = strings.Trim(value, `"'`)
+			}
+		} else if b.Path == "" && looksLikePath(f) {
+			b.Path = f
+		}
+	}
+	b.Lang = NormalizeLang(b.Lang)
+	return b
+}
+
+var pathRe = regexp.MustCompile(`^[\w./-]*[\w-]\.[a-zA-Z][a-zA-Z0-9]*$|^[\w.-]+/[\w./-]+$`)
+
+// looksLikePath reports whether s looks like a file path: it has a directory
+// or an extension.
+func looksLikePath(s string) bool {
+	return pathRe.MatchString(s)
+}
+
+var langAliases = map[string]string{
+	"golang":     "go",
+	"py":         "python",
+	"python3":    "python",
+	"js":         "javascript",
+	"ts":         "typescript",
+	"sh":         "bash",
+	"shell":      "bash",
+	"zsh":        "bash",
+	"yml":        "yaml",
+	"rs":         "rust",
+	"c++":        "cpp",
+	"rb":         "ruby",
+	"md":         "markdown",
+	"dockerfile": "docker",
+}
+
+// NormalizeLang returns the canonical name of the language lang, like "go"
+// for "golang" or "bash" for "sh".
+func NormalizeLang(lang string) string {
+	lang = strings.ToLower(lang)
+	if canonical, ok := langAliases[lang]; ok {
+		return canonical
+	}
+	return lang
+}
+
+var langExtensions = map[string]string{
+	"go":         ".go",
+	"python":     ".py",
+	"javascript": ".js",
+	"typescript": ".ts",
+	"bash":       ".sh",
+	"yaml":       ".yaml",
+	"json":       ".json",
+	"rust":       ".rs",
+	"c":          ".c",
+	"cpp":        ".cpp",
+	"java":       ".java",
+	"ruby":       ".rb",
+	"markdown":   ".md",
+	"html":       ".html",
+	"css":        ".css",
+	"sql":        ".sql",
+	"toml":       ".toml",
+}
+
+// Extension returns the usual file extension (like ".go") of files in the
+// language lang, or ".txt" for unknown languages.
+func Extension(lang string) string {
+	if ext, ok := langExtensions[NormalizeLang(lang)]; ok {
+		return ext
+	}
+	return ".txt"
+}
@@ -0,0 +1,75 @@
+package codeblocks
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	md := "Here's the program:\n\n```go main.go\npackage main\n\nfunc main() {}\n```\n\nRun it with:\n\n~~~sh\ngo run .\n~~~\n\nAnd the output:\n```\nok\n```\n"
+	want := []Block{
+		{Lang: "go", Path: "main.go", Code: "package main\n\nfunc main() {}\n"},
+		{Lang: "bash", Code: "go run .\n"},
+		{Code: "ok\n"},
+	}
+	if got := Extract(md); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestExtractNested(t *testing.T) {
+	// A longer fence can contain shorter ones; a ~~~ fence isn't closed by
+	// ```.
+	md := "````markdown\n```go\nx := 1\n```\n````\n~~~\n```\n~~~\nunclosed:\n```python\nprint(1)"
+	want := []Block{
+		{Lang: "markdown", Code: "```go\nx := 1\n```\n"},
+		{Code: "```\n"},
+		{Lang: "python", Code: "print(1)\n"},
+	}
+	if got := Extract(md); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestExtractNone(t *testing.T) {
+	if got := Extract("no code, just ```inline``` spans"); len(got) != 0 {
+		t.Errorf("got %+v, want no blocks", got)
+	}
+}
+
+func TestParseInfo(t *testing.T) {
+	var tests = []struct {
+		info string
+		lang string
+		path string
+	}{
+		{"", "", ""},
+		{"Go", "go", ""},
+		{"golang", "go", ""},
+		{"go main.go", "go", "main.go"},
+		{"go:cmd/tool/main.go", "go", "cmd/tool/main.go"},
+		{`go title="main.go"`, "go", "main.go"},
+		{"py filename=scripts/run.py", "python", "scripts/run.py"},
+		{"main.go", "go", "main.go"},
+		{"{python}", "python", ""},
+		{"go linenums=1", "go", ""},
+		{"c++ 1.5", "cpp", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.info, func(t *testing.T) {
+			b := parseInfo(tt.info)
+			if b.Lang != tt.lang || b.Path != tt.path {
+				t.Errorf("got lang=%q path=%q, want lang=%q path=%q", b.Lang, b.Path, tt.lang, tt.path)
+			}
+		})
+	}
+}
+
+func TestExtension(t *testing.T) {
+	for lang, want := range map[string]string{"go": ".go", "sh": ".sh", "Python": ".py", "": ".txt", "cobol": ".txt"} {
+		if got := Extension(lang); got != want {
+			t.Errorf("Extension(%q) = %q, want %q", lang, got, want)
+		}
+	}
+}
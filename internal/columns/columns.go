@@ -0,0 +1,107 @@
+// Package columns lays out texts next to each other in columns, for plain
+// text output.
+package columns
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// separator is placed between columns.
+const separator = " │ "
+
+// minWidth is the minimal width of a column.
+const minWidth = 10
+
+// Format lays out texts next to each other, in columns that fit in total in
+// width characters (if possible; columns are at least minWidth wide). Lines
+// of text too long for their column are wrapped.
+func Format(texts []string, width int) string {
+	if len(texts) == 0 {
+		return ""
+	}
+	colWidth := max(minWidth, (width-len(separator)*(len(texts)-1))/len(texts))
+
+	cols := make([][]string, len(texts))
+	numRows := 0
+	for i, text := range texts {
+		cols[i] = Wrap(text, colWidth)
+		numRows = max(numRows, len(cols[i]))
+	}
+
+	var sb strings.Builder
+	for row := range numRows {
+		var line strings.Builder
+		for i, col := range cols {
+			if i > 0 {
+				line.WriteString(separator)
+			}
+			var cell string
+			if row < len(col) {
+				cell = col[row]
+			}
+			line.WriteString(cell)
+			if i < len(cols)-1 {
+				line.WriteString(strings.Repeat(" ", colWidth-utf8.RuneCountInString(cell)))
+			}
+		}
+		sb.WriteString(strings.TrimRight(line.String(), " "))
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// Wrap splits text into lines of at most width characters. Long lines are
+// broken between words where possible, and words longer than width are
+// broken where needed. Tabs are expanded to 4 spaces.
+func Wrap(text string, width int) []string {
+	text = strings.ReplaceAll(text, "\t", "    ")
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		line = strings.TrimRight(line, " ")
+		if utf8.RuneCountInString(line) <= width {
+			lines = append(lines, line)
+			continue
+		}
+
+		// Wrapped lines keep the indentation of the original line, if it
+		// leaves room for text.
+		indent := line[:len(line)-len(strings.TrimLeft(line, " "))]
+		if len(indent) > width/2 {
+			indent = ""
+		}
+		cur := ""
+		for _, word := range strings.Fields(line) {
+			if cur != "" {
+				if utf8.RuneCountInString(cur)+1+utf8.RuneCountInString(word) <= width {
+					cur += " " + word
+					continue
+				}
+				lines = append(lines, cur)
+			}
+			// Break a word that doesn't fit in a line of its own.
+			for utf8.RuneCountInString(indent+word) > width {
+				head, tail := splitRunes(word, width-len(indent))
+				lines = append(lines, indent+head)
+				word = tail
+			}
+			cur = indent + word
+		}
+		if cur != "" {
+			lines = append(lines, cur)
+		}
+	}
+	return lines
+}
+
+// splitRunes splits s after its first n runes.
+func splitRunes(s string, n int) (string, string) {
+	i := 0
+	for j := range s {
+		if i == n {
+			return s[:j], s[j:]
+		}
+		i++
+	}
+	return s, ""
+}
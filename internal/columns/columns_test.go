@@ -0,0 +1,43 @@
+package columns
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWrap(t *testing.T) {
+	var tests = []struct {
+		name  string
+		text  string
+		width int
+		want  []string
+	}{
+		{"short", "hello\nworld\n", 10, []string{"hello", "world"}},
+		{"words", "the quick brown fox jumps", 10, []string{"the quick", "brown fox", "jumps"}},
+		{"long word", "a abcdefghijklmnop b", 6, []string{"a", "abcdef", "ghijkl", "mnop b"}},
+		{"indent", "  - one two three four", 12, []string{"  - one two", "  three four"}},
+		{"tab", "\tx", 10, []string{"    x"}},
+		{"empty lines", "a\n\nb", 5, []string{"a", "", "b"}},
+		{"unicode", "ééé ééé", 4, []string{"ééé", "ééé"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Wrap(tt.text, tt.width); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	got := Format([]string{"first column text", "second"}, 23)
+	want := "first      │ second\ncolumn     │\ntext       │\n"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	if got := Format(nil, 80); got != "" {
+		t.Errorf("got %q for no texts", got)
+	}
+}
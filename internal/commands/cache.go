@@ -0,0 +1,285 @@
+package commands
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/eliben/gemini-cli/internal/respcache"
+	"github.com/spf13/cobra"
+	"google.golang.org/genai"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the local cache of responses",
+	Long:  strings.TrimSpace(cacheUsage),
+
+	// 'cache' is a parent of subcommands, and doesn't do anything on its own.
+	// Therefore we don't define a Run: function for it.
+}
+
+var cacheUsage = `
+Manage the local cache of responses used by 'prompt --cache'.
+
+The cache is a SQLite database in the gemini-cli data directory, which can
+be set with the GEMINI_CLI_DATA_DIR environment variable.
+`
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show statistics about the cached responses",
+	Args:  cobra.NoArgs,
+	Run:   runCacheStatsCmd,
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove all the cached responses",
+	Args:  cobra.NoArgs,
+	Run:   runCacheClearCmd,
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheStatsCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+}
+
+func openResponseCache() *respcache.Cache {
+	path, err := respcache.DefaultPath()
+	if err != nil {
+		log.Fatal(err)
+	}
+	c, err := respcache.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return c
+}
+
+func runCacheStatsCmd(cmd *cobra.Command, args []string) {
+	c := openResponseCache()
+	defer c.Close()
+
+	st, err := c.Stats()
+	if err != nil {
+		log.Fatal(err)
+	}
+	w := cmd.OutOrStdout()
+	fmt.Fprintf(w, "entries: %d\n", st.Entries)
+	fmt.Fprintf(w, "size:    %d bytes\n", st.Bytes)
+	fmt.Fprintf(w, "hits:    %d\n", st.Hits)
+	if st.Entries > 0 {
+		fmt.Fprintf(w, "oldest:  %s\n", st.Oldest.Local().Format(time.DateTime))
+		fmt.Fprintf(w, "newest:  %s\n", st.Newest.Local().Format(time.DateTime))
+	}
+}
+
+func runCacheClearCmd(cmd *cobra.Command, args []string) {
+	c := openResponseCache()
+	defer c.Close()
+
+	n, err := c.Clear()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "removed %d cached responses\n", n)
+}
+
+// responseCache looks up and stores the response for a single request in
+// the response cache. Failures to use the cache are reported as warnings,
+// and never fail the command.
+type responseCache struct {
+	c         *respcache.Cache
+	key       string
+	modelName string
+}
+
+// newResponseCache creates a responseCache for the request sending parts to
+// model (named modelName) after the chat history. It returns nil if the
+// cache can't be opened.
+func newResponseCache(modelName string, model *generativeModel, history []*genai.Content, parts []*genai.Part) *responseCache {
+	path, err := respcache.DefaultPath()
+	if err != nil {
+		warnCacheFailure(err)
+		return nil
+	}
+	c, err := respcache.Open(path)
+	if err != nil {
+		warnCacheFailure(err)
+		return nil
+	}
+	return &responseCache{c: c, key: responseCacheKey(modelName, model, history, parts), modelName: modelName}
+}
+
+// get returns the cached response, or nil if there's none.
+func (rc *responseCache) get() *genai.GenerateContentResponse {
+	data, found, err := rc.c.Get(rc.key)
+	if err != nil {
+		warnCacheFailure(err)
+		return nil
+	}
+	if !found {
+		return nil
+	}
+
+	var cr cachedResponse
+	if err := json.Unmarshal(data, &cr); err != nil {
+		warnCacheFailure(err)
+		return nil
+	}
+	return cr.toResponse()
+}
+
+// put stores resp in the cache.
+func (rc *responseCache) put(resp *genai.GenerateContentResponse) {
+	data, err := json.Marshal(newCachedResponse(resp))
+	if err != nil {
+		warnCacheFailure(err)
+		return
+	}
+	if err := rc.c.Put(rc.key, rc.modelName, data); err != nil {
+		warnCacheFailure(err)
+	}
+}
+
+func (rc *responseCache) Close() {
+	rc.c.Close()
+}
+
+func warnCacheFailure(err error) {
+	fmt.Fprintf(os.Stderr, "warning: response cache: %v\n", err)
+}
+
+// responseCacheKey returns the cache key for a request sending parts to
+// model (named modelName) after the chat history. It hashes everything that
+// affects the response: the model name, generation config, safety settings,
+// system instruction, cached content, history and the full contents of
+// parts.
+func responseCacheKey(modelName string, model *generativeModel, history []*genai.Content, parts []*genai.Part) string {
+	// The system instruction is hashed separately below, and requests with
+	// tools aren't cached.
+	config := model.GenerateContentConfig
+	config.SystemInstruction = nil
+	config.Tools = nil
+	b, err := json.Marshal(struct {
+		Model  string
+		Config genai.GenerateContentConfig
+	}{modelName, config})
+	if err != nil {
+		panic(err)
+	}
+
+	h := sha256.New()
+	writeHashField(h, b)
+	if si := model.SystemInstruction; si != nil {
+		writeHashField(h, []byte("system"))
+		for _, p := range si.Parts {
+			hashPart(h, p)
+		}
+	}
+	for _, c := range history {
+		writeHashField(h, []byte("turn"))
+		writeHashField(h, []byte(c.Role))
+		for _, p := range c.Parts {
+			hashPart(h, p)
+		}
+	}
+	writeHashField(h, []byte("parts"))
+	for _, p := range parts {
+		hashPart(h, p)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func hashPart(h hash.Hash, part *genai.Part) {
+	switch {
+	case part.InlineData != nil:
+		writeHashField(h, []byte("blob"))
+		writeHashField(h, []byte(part.InlineData.MIMEType))
+		writeHashField(h, part.InlineData.Data)
+	case isTextPart(part):
+		writeHashField(h, []byte("text"))
+		writeHashField(h, []byte(part.Text))
+	default:
+		b, err := json.Marshal(part)
+		if err != nil {
+			panic(err)
+		}
+		writeHashField(h, []byte("part"))
+		writeHashField(h, b)
+	}
+}
+
+// writeHashField writes b to h, prefixed by its length so that the
+// boundaries between fields are unambiguous.
+func writeHashField(h hash.Hash, b []byte) {
+	binary.Write(h, binary.LittleEndian, uint64(len(b)))
+	h.Write(b)
+}
+
+// cachedResponse is the form in which responses are stored in the cache.
+// Only text parts of the candidates are kept.
+type cachedResponse struct {
+	Candidates     []cachedCandidate                            `json:"candidates"`
+	PromptFeedback *genai.GenerateContentResponsePromptFeedback `json:"prompt_feedback,omitempty"`
+	UsageMetadata  *genai.GenerateContentResponseUsageMetadata  `json:"usage_metadata,omitempty"`
+}
+
+type cachedCandidate struct {
+	Index         int32                 `json:"index"`
+	Text          []string              `json:"text"`
+	FinishReason  genai.FinishReason    `json:"finish_reason"`
+	SafetyRatings []*genai.SafetyRating `json:"safety_ratings,omitempty"`
+}
+
+func newCachedResponse(resp *genai.GenerateContentResponse) *cachedResponse {
+	cr := &cachedResponse{
+		PromptFeedback: resp.PromptFeedback,
+		UsageMetadata:  resp.UsageMetadata,
+	}
+	for _, c := range resp.Candidates {
+		cc := cachedCandidate{
+			Index:         c.Index,
+			FinishReason:  c.FinishReason,
+			SafetyRatings: c.SafetyRatings,
+		}
+		if c.Content != nil {
+			for _, part := range c.Content.Parts {
+				if isTextPart(part) {
+					cc.Text = append(cc.Text, part.Text)
+				}
+			}
+		}
+		cr.Candidates = append(cr.Candidates, cc)
+	}
+	return cr
+}
+
+func (cr *cachedResponse) toResponse() *genai.GenerateContentResponse {
+	resp := &genai.GenerateContentResponse{
+		PromptFeedback: cr.PromptFeedback,
+		UsageMetadata:  cr.UsageMetadata,
+	}
+	for _, cc := range cr.Candidates {
+		c := &genai.Candidate{
+			Index:         cc.Index,
+			FinishReason:  cc.FinishReason,
+			SafetyRatings: cc.SafetyRatings,
+			Content:       &genai.Content{Role: genai.RoleModel},
+		}
+		for _, text := range cc.Text {
+			c.Content.Parts = append(c.Content.Parts, genai.NewPartFromText(text))
+		}
+		resp.Candidates = append(resp.Candidates, c)
+	}
+	return resp
+}
@@ -0,0 +1,321 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/eliben/gemini-cli/internal/jsonschema"
+	"github.com/eliben/gemini-cli/internal/templates"
+	"github.com/spf13/cobra"
+	"google.golang.org/genai"
+)
+
+var cacheContentCmd = &cobra.Command{
+	Use:   "cache-content",
+	Short: "Manage content cached with the Gemini context caching API",
+	Long:  strings.TrimSpace(cacheContentUsage),
+
+	// 'cache-content' is a parent of subcommands, and doesn't do anything on
+	// its own. Therefore we don't define a Run: function for it.
+}
+
+var cacheContentUsage = `
+Manage content cached with the Gemini context caching API.
+
+Cached content (like a long document, along with a system instruction) is
+stored by the API for a limited time (its TTL), and can be used as the
+beginning of prompts with the --cached-content flag of 'prompt' and 'chat',
+without sending it again. Cached input tokens are billed at a lower rate.
+
+Cached content is tied to a specific version of a model, like
+gemini-2.0-flash-001, set with --model when it's created, and has a minimal
+size which depends on the model.
+
+Not to be confused with 'cache', which manages the local cache of responses.
+`
+
+var cacheContentCreateCmd = &cobra.Command{
+	Use:   "create <content or '-'>...",
+	Short: "Cache content",
+	Long: strings.TrimSpace(`
+Cache content, given as a sequence of parts like the arguments of 'prompt'
+(text, files, URLs or '-' for standard input), and print the name of the
+cached content.`),
+	Args: cobra.MinimumNArgs(1),
+	Run:  runCacheContentCreateCmd,
+}
+
+var cacheContentListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List cached content",
+	Args:    cobra.NoArgs,
+	Run:     runCacheContentListCmd,
+}
+
+var cacheContentShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Show information about cached content",
+	Args:  cobra.ExactArgs(1),
+	Run:   runCacheContentShowCmd,
+}
+
+var cacheContentUpdateTTLCmd = &cobra.Command{
+	Use:   "update-ttl <name> <ttl>",
+	Short: "Set the time cached content is kept for, from now (like 30m or 2h)",
+	Args:  cobra.ExactArgs(2),
+	Run:   runCacheContentUpdateTTLCmd,
+}
+
+var cacheContentDeleteCmd = &cobra.Command{
+	Use:     "delete <name>...",
+	Aliases: []string{"rm"},
+	Short:   "Delete cached content",
+	Args:    cobra.MinimumNArgs(1),
+	Run:     runCacheContentDeleteCmd,
+}
+
+func init() {
+	rootCmd.AddCommand(cacheContentCmd)
+	cacheContentCmd.AddCommand(cacheContentCreateCmd)
+	cacheContentCmd.AddCommand(cacheContentListCmd)
+	cacheContentCmd.AddCommand(cacheContentShowCmd)
+	cacheContentCmd.AddCommand(cacheContentUpdateTTLCmd)
+	cacheContentCmd.AddCommand(cacheContentDeleteCmd)
+
+	cacheContentCreateCmd.Flags().Duration("ttl", time.Hour, "time to keep the cached content for")
+	cacheContentCreateCmd.Flags().String("display-name", "", "display name of the cached content")
+	cacheContentCreateCmd.Flags().Bool("literal", false, "send arguments without a prefix (like @ or url:) as text, without guessing whether they're files or URLs")
+	addSystemFlags(cacheContentCreateCmd)
+	addUploadFlags(cacheContentCreateCmd)
+	addURLFlags(cacheContentCreateCmd)
+}
+
+// addCachedContentFlags adds the flag for using cached content in prompts to
+// cmd.
+func addCachedContentFlags(cmd *cobra.Command) {
+	cmd.Flags().String("cached-content", "", "name of cached content (see the 'cache-content' command) to send before the prompt")
+}
+
+// cachedContentName returns the full name of the cached content named name,
+// which may omit the "cachedContents/" prefix.
+func cachedContentName(name string) string {
+	if strings.ContainsRune(name, '/') {
+		return name
+	}
+	return "cachedContents/" + name
+}
+
+// cachedContentFlag returns the name of the cached content set with the
+// --cached-content flag of cmd, or "" if it isn't set. It checks that
+// it's not used with a system instruction or tools, which can only be set
+// when the content is cached.
+func cachedContentFlag(cmd *cobra.Command, system string) string {
+	name := mustGetStringFlag(cmd, "cached-content")
+	if name == "" {
+		return ""
+	}
+	if system != "" {
+		log.Fatal("--cached-content can't be used with a system prompt; set it when creating the cached content")
+	}
+	if mustGetStringFlag(cmd, "tools") != "" {
+		log.Fatal("--cached-content can't be used with --tools")
+	}
+	if mustGetBoolFlag(cmd, "code-exec") {
+		log.Fatal("--cached-content can't be used with --code-exec")
+	}
+	if mustGetBoolFlag(cmd, "grounding") {
+		log.Fatal("--cached-content can't be used with --grounding")
+	}
+	return cachedContentName(name)
+}
+
+// cachedContentModelName returns the name of the model the cached content
+// named name was created for. If the --model flag of cmd is set, it must
+// be the same model.
+func cachedContentModelName(ctx context.Context, cmd *cobra.Command, client *genai.Client, name string) string {
+	cc, err := client.Caches.Get(ctx, name, nil)
+	if err != nil {
+		log.Fatalf("getting cached content %s: %v", name, err)
+	}
+	modelName := strings.TrimPrefix(cc.Model, "models/")
+	if flag := mustGetStringFlag(cmd, "model"); cmd.Flags().Changed("model") && strings.TrimPrefix(flag, "models/") != modelName {
+		log.Fatalf("cached content %s was created for model %s, not %s", name, modelName, flag)
+	}
+	return modelName
+}
+
+// newCachedContentPromptModel is like newPromptModel, but creates a model
+// using the cached content named name, for the model it was created for.
+func newCachedContentPromptModel(ctx context.Context, cmd *cobra.Command, client *genai.Client, t *templates.Template, name string) (string, *generativeModel, *jsonschema.Schema) {
+	modelName := cachedContentModelName(ctx, cmd, client, name)
+	model, schema := newNamedPromptModel(cmd, client, t, modelName)
+	model.CachedContent = name
+	return modelName, model, schema
+}
+
+// reportCachedTokens writes the number of prompt tokens of resp taken from
+// cached content, and the number of fresh ones, to w.
+func reportCachedTokens(w io.Writer, resp *genai.GenerateContentResponse) {
+	if resp == nil || resp.UsageMetadata == nil {
+		return
+	}
+	um := resp.UsageMetadata
+	fmt.Fprintf(w, "prompt tokens: %d cached + %d fresh\n", um.CachedContentTokenCount, um.PromptTokenCount-um.CachedContentTokenCount)
+}
+
+func runCacheContentCreateCmd(cmd *cobra.Command, args []string) {
+	ttl := mustGetDurationFlag(cmd, "ttl")
+	if ttl <= 0 {
+		log.Fatal("expect --ttl to be positive")
+	}
+	system := systemInstruction(cmd, "")
+
+	ctx := context.Background()
+	client, err := newGenaiClient(ctx, cmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	uploader := newFileUploader(cmd, client)
+	fetcher, err := newURLFetcher(cmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+	specs, err := parsePartSpecs(args, mustGetBoolFlag(cmd, "literal"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	var parts []*genai.Part
+	for _, spec := range specs {
+		var part *genai.Part
+		switch spec.kind {
+		case stdinPart:
+			b, err := io.ReadAll(cmd.InOrStdin())
+			if err != nil {
+				log.Fatal("error reading content from stdin:", err)
+			}
+			part = genai.NewPartFromText(string(b))
+		case urlPart:
+			part, err = fetcher.part(ctx, spec.value)
+		case filePart:
+			part, err = uploader.partFromFile(ctx, spec.value)
+		default:
+			part = genai.NewPartFromText(spec.value)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		parts = append(parts, part)
+	}
+
+	config := &genai.CreateCachedContentConfig{
+		DisplayName: mustGetStringFlag(cmd, "display-name"),
+		Contents:    []*genai.Content{genai.NewContentFromParts(parts, genai.RoleUser)},
+		TTL:         ttl,
+	}
+	if system != "" {
+		config.SystemInstruction = genai.NewContentFromText(system, genai.RoleUser)
+	}
+	cc, err := client.Caches.Create(ctx, mustGetStringFlag(cmd, "model"), config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%s\t%s tokens\texpires %s\n", cc.Name, cachedContentTokens(cc), cc.ExpireTime.Local().Format(time.DateTime))
+}
+
+func runCacheContentListCmd(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	client, err := newGenaiClient(ctx, cmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for cc, err := range client.Caches.All(ctx) {
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s tokens\texpires %s\n", cc.Name, cc.DisplayName, strings.TrimPrefix(cc.Model, "models/"),
+			cachedContentTokens(cc), cc.ExpireTime.Local().Format(time.DateTime))
+	}
+	w.Flush()
+}
+
+func runCacheContentShowCmd(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	client, err := newGenaiClient(ctx, cmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cc, err := client.Caches.Get(ctx, cachedContentName(args[0]), nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	printCachedContent(os.Stdout, cc)
+}
+
+func runCacheContentUpdateTTLCmd(cmd *cobra.Command, args []string) {
+	ttl, err := time.ParseDuration(args[1])
+	if err != nil {
+		log.Fatalf("expect a duration like 30m or 2h for the TTL, got %q", args[1])
+	}
+	if ttl <= 0 {
+		log.Fatal("expect the TTL to be positive")
+	}
+
+	ctx := context.Background()
+	client, err := newGenaiClient(ctx, cmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cc, err := client.Caches.Update(ctx, cachedContentName(args[0]), &genai.UpdateCachedContentConfig{TTL: ttl})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%s\texpires %s\n", cc.Name, cc.ExpireTime.Local().Format(time.DateTime))
+}
+
+func runCacheContentDeleteCmd(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	client, err := newGenaiClient(ctx, cmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, name := range args {
+		if _, err := client.Caches.Delete(ctx, cachedContentName(name), nil); err != nil {
+			log.Fatalf("deleting %s: %v", name, err)
+		}
+	}
+}
+
+// printCachedContent prints information about cc to w.
+func printCachedContent(w io.Writer, cc *genai.CachedContent) {
+	fmt.Fprintf(w, "name:         %s\n", cc.Name)
+	if cc.DisplayName != "" {
+		fmt.Fprintf(w, "display name: %s\n", cc.DisplayName)
+	}
+	fmt.Fprintf(w, "model:        %s\n", strings.TrimPrefix(cc.Model, "models/"))
+	fmt.Fprintf(w, "tokens:       %s\n", cachedContentTokens(cc))
+	fmt.Fprintf(w, "created:      %s\n", cc.CreateTime.Local().Format(time.DateTime))
+	fmt.Fprintf(w, "updated:      %s\n", cc.UpdateTime.Local().Format(time.DateTime))
+	fmt.Fprintf(w, "expires:      %s\n", cc.ExpireTime.Local().Format(time.DateTime))
+}
+
+// cachedContentTokens returns the number of tokens of cc, or "?" if it's
+// unknown.
+func cachedContentTokens(cc *genai.CachedContent) string {
+	if cc.UsageMetadata == nil {
+		return "?"
+	}
+	return fmt.Sprint(cc.UsageMetadata.TotalTokenCount)
+}
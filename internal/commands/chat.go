@@ -3,14 +3,17 @@ package commands
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/google/generative-ai-go/genai"
 	"github.com/spf13/cobra"
-	"google.golang.org/api/iterator"
+	"google.golang.org/genai"
 )
 
 var chatCmd = &cobra.Command{
@@ -22,33 +25,62 @@ var chatCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(chatCmd)
+
+	addSystemFlags(chatCmd)
+	addGenerationFlags(chatCmd)
+	chatCmd.Flags().Int32("candidates", 1, "number of response candidates to generate for each message, with a request each; more than 1 disables streaming")
+	addToolsFlags(chatCmd)
+	addCodeExecFlags(chatCmd)
+	addGroundingFlags(chatCmd)
+	addLogFlags(chatCmd)
+	addUploadFlags(chatCmd)
+	addTokenBudgetFlags(chatCmd)
+	addRenderFlags(chatCmd)
+	addCachedContentFlags(chatCmd)
+	addUsageFlags(chatCmd)
 }
 
 func runChatCmd(cmd *cobra.Command, args []string) {
+	candidates, _ := cmd.Flags().GetInt32("candidates")
+	if candidates < 1 {
+		log.Fatalf("expect --candidates to be at least 1, got %d", candidates)
+	}
+	if candidates > 1 && mustGetStringFlag(cmd, "tools") != "" {
+		log.Fatal("--candidates can't be used with --tools")
+	}
+	system := systemInstruction(cmd, "")
+	cachedContent := cachedContentFlag(cmd, system)
+
 	ctx := context.Background()
 	client, err := newGenaiClient(ctx, cmd)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer client.Close()
 
 	modelName, _ := cmd.Flags().GetString("model")
-	model := client.GenerativeModel(modelName)
-	model.SafetySettings = []*genai.SafetySetting{
-		{
-			Category:  genai.HarmCategoryDangerousContent,
-			Threshold: genai.HarmBlockNone,
-		},
-		{
-			Category:  genai.HarmCategoryHarassment,
-			Threshold: genai.HarmBlockNone,
-		},
+	if cachedContent != "" {
+		modelName = cachedContentModelName(ctx, cmd, client, cachedContent)
 	}
+	model := newGenerativeModel(client, modelName)
+	model.CachedContent = cachedContent
+	applyGenerationFlags(cmd, model)
+	applySystemInstruction(model, system)
+
+	reader := bufio.NewReader(os.Stdin)
+	tr := newToolRunner(cmd, reader)
+	model.Tools = modelTools(cmd, modelName, tr)
+
+	uploader := newFileUploader(cmd, client)
+	budget := newTokenBudget(cmd)
+	usage := newUsageMeter(cmd)
+	render := shouldRender(cmd)
+
+	logger := newExchangeLogger(cmd, true)
+	defer logger.Close()
 
-	session := model.StartChat()
+	session := model.startChat()
 	fmt.Printf("Chatting with %s\n", modelName)
 	fmt.Println("Type 'exit' or 'quit' to exit, or '$load <file path>' to load a file")
-	reader := bufio.NewReader(os.Stdin)
 
 	for {
 		fmt.Print("> ")
@@ -59,37 +91,110 @@ func runChatCmd(cmd *cobra.Command, args []string) {
 			break
 		}
 
-		var inputPart genai.Part
+		var inputPart *genai.Part
 		// Detect a special chat command.
 		if path, found := strings.CutPrefix(text, "$load"); found {
-			part, err := getPartFromFile(strings.TrimSpace(path))
+			part, err := uploader.partFromFile(ctx, strings.TrimSpace(path))
 			if err != nil {
 				log.Fatalf("error loading file %s: %v", path, err)
 			}
 			inputPart = part
 		} else {
-			inputPart = genai.Text(text)
+			inputPart = genai.NewPartFromText(text)
 		}
 
-		iter := session.SendMessageStream(ctx, inputPart)
+		// Messages that don't fit in the token budget (along with the history)
+		// are reported, and not sent.
+		parts, err := budget.check(ctx, model, session.History, []*genai.Part{inputPart})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
 
-	ResponseIter:
-		for {
-			resp, err := iter.Next()
-			if err == iterator.Done {
-				break ResponseIter
-			}
-			if err != nil {
-				log.Fatal(err)
-			}
-			if len(resp.Candidates) >= 0 {
-				c := resp.Candidates[0]
-				if c.Content != nil {
-					for _, part := range c.Content.Parts {
-						fmt.Print(part)
-					}
+		start := time.Now()
+		var resp *genai.GenerateContentResponse
+		if candidates > 1 {
+			resp, err = sendMessageCandidates(ctx, model, session, parts, int(candidates))
+		} else {
+			resp, err = sendMessage(ctx, session, parts, newTextStreamOutput(render), tr)
+		}
+		var blocked *blockedError
+		if errors.As(err, &blocked) {
+			// Report the block and drop the unanswered message from the
+			// history, so the chat can go on.
+			reportBlocked(os.Stderr, blocked)
+			session.History = session.History[:len(session.History)-1]
+		} else if err != nil {
+			log.Fatal(err)
+		} else {
+			if candidates > 1 {
+				if render {
+					printRenderedCandidates(os.Stdout, resp)
+				} else {
+					printCandidates(os.Stdout, resp)
 				}
 			}
+			reportFinishReasons(os.Stderr, resp)
+			if cachedContent != "" {
+				reportCachedTokens(os.Stderr, resp)
+			}
+			logger.log(modelName, model.SystemInstruction, nil, parts, resp, time.Since(start))
+			usage.report(modelName, responseUsage(resp))
+		}
+	}
+}
+
+// sendMessageCandidates sends parts to the model in session and returns a
+// response with n candidates. Chat sessions only generate a single candidate
+// per request, so the message is sent in n concurrent requests with the
+// history of session, and the candidates (and token usage) of their
+// responses are combined. Only the first candidate is added to the history
+// of session, to continue the chat with.
+func sendMessageCandidates(ctx context.Context, model *generativeModel, session *chatSession, parts []*genai.Part, n int) (*genai.GenerateContentResponse, error) {
+	historyLen := len(session.History)
+	sessions := []*chatSession{session}
+	for range n - 1 {
+		s := model.startChat()
+		s.History = slices.Clone(session.History)
+		sessions = append(sessions, s)
+	}
+
+	resps := make([]*genai.GenerateContentResponse, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i, s := range sessions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resps[i], errs[i] = s.sendMessage(ctx, parts...)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			// Like a failed message of a single request, the unanswered
+			// message is left at the end of the history.
+			session.History = session.History[:historyLen+1]
+			return nil, err
+		}
+	}
+
+	resp := &genai.GenerateContentResponse{
+		PromptFeedback: resps[0].PromptFeedback,
+		UsageMetadata:  &genai.GenerateContentResponseUsageMetadata{},
+	}
+	for _, r := range resps {
+		for _, c := range r.Candidates {
+			c.Index = int32(len(resp.Candidates))
+			resp.Candidates = append(resp.Candidates, c)
+		}
+		if u := r.UsageMetadata; u != nil {
+			resp.UsageMetadata.PromptTokenCount += u.PromptTokenCount
+			resp.UsageMetadata.CachedContentTokenCount += u.CachedContentTokenCount
+			resp.UsageMetadata.CandidatesTokenCount += u.CandidatesTokenCount
+			resp.UsageMetadata.ThoughtsTokenCount += u.ThoughtsTokenCount
+			resp.UsageMetadata.TotalTokenCount += u.TotalTokenCount
 		}
 	}
+	return resp, nil
 }
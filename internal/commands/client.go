@@ -2,64 +2,72 @@ package commands
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/url"
+	"os"
+	"time"
 
 	"github.com/eliben/gemini-cli/internal/apikey"
-	"github.com/google/generative-ai-go/genai"
+	"github.com/eliben/gemini-cli/internal/retry"
 	"github.com/spf13/cobra"
-	"google.golang.org/api/option"
+	"google.golang.org/genai"
 )
 
 // newGenaiClient creates a new genai.Client given the configuration of
 // cmd flags (for API key, proxy selection, etc.)
+//
+// Requests failing with transient errors (like rate limiting) are retried
+// according to the --retries and --retry-max-wait flags.
 func newGenaiClient(ctx context.Context, cmd *cobra.Command) (*genai.Client, error) {
-	key := apikey.Get(cmd)
-
-	var clientOpts []option.ClientOption
-	if proxyURL, _ := cmd.Flags().GetString("proxy"); len(proxyURL) > 0 {
-		c := &http.Client{Transport: &proxyRoundTripper{
-			APIKey:   key,
-			ProxyURL: proxyURL,
-		}}
-
-		clientOpts = append(clientOpts, option.WithHTTPClient(c))
-	} else {
-		clientOpts = append(clientOpts, option.WithAPIKey(key))
+	retries := mustGetIntFlag(cmd, "retries")
+	if retries < 0 {
+		return nil, fmt.Errorf("expect --retries to be non-negative, got %d", retries)
+	}
+	proxyURL, _ := cmd.Flags().GetString("proxy")
+	transport, err := newProxyTransport(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --proxy: %w", err)
 	}
 
-	client, err := genai.NewClient(ctx, clientOpts...)
-	return client, err
-}
-
-type proxyRoundTripper struct {
-	// APIKey is the API Key to set on requests.
-	APIKey string
-
-	// ProxyURL is the URL of the proxy server. If empty, no proxy is used.
-	ProxyURL string
+	c := &http.Client{Transport: &retry.Transport{
+		Base:       transport,
+		MaxRetries: retries,
+		MaxWait:    mustGetDurationFlag(cmd, "retry-max-wait"),
+		OnRetry: func(resp *http.Response, n int, wait time.Duration) {
+			fmt.Fprintf(os.Stderr, "%s; retrying in %v (%d/%d)\n", resp.Status, wait.Round(time.Millisecond), n, retries)
+		},
+	}}
+	return genai.NewClient(ctx, &genai.ClientConfig{
+		APIKey:     apikey.Get(cmd),
+		Backend:    genai.BackendGeminiAPI,
+		HTTPClient: c,
+	})
 }
 
-func (t *proxyRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+// newProxyTransport creates an HTTP transport that connects through the proxy
+// server at proxyURL. If proxyURL is empty, the transport uses the proxy
+// configured in the environment, like http.DefaultTransport.
+func newProxyTransport(proxyURL string) (*http.Transport, error) {
 	transport := http.DefaultTransport.(*http.Transport).Clone()
-
-	if t.ProxyURL != "" {
-		proxyURL, err := url.Parse(t.ProxyURL)
+	if proxyURL != "" {
+		u, err := url.Parse(proxyURL)
 		if err != nil {
 			return nil, err
 		}
-		transport.Proxy = http.ProxyURL(proxyURL)
+		transport.Proxy = http.ProxyURL(u)
 	}
+	return transport, nil
+}
 
-	newReq := req.Clone(req.Context())
-	vals := newReq.URL.Query()
-	vals.Set("key", t.APIKey)
-	newReq.URL.RawQuery = vals.Encode()
-
-	resp, err := transport.RoundTrip(newReq)
+// newHTTPClient creates an HTTP client for requests that don't go to the
+// Gemini API (like fetching URLs in prompts), using the same proxy settings
+// as newGenaiClient. A zero timeout means no timeout.
+func newHTTPClient(cmd *cobra.Command, timeout time.Duration) (*http.Client, error) {
+	proxyURL, _ := cmd.Flags().GetString("proxy")
+	transport, err := newProxyTransport(proxyURL)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("invalid --proxy: %w", err)
 	}
-
-	return resp, nil
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
 }
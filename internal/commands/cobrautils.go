@@ -1,6 +1,10 @@
 package commands
 
-import "github.com/spf13/cobra"
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+)
 
 // mustGetStringFlag gets a string flag value from cmd, and panics if this
 // results in an error (for example, if such a flag wasn't defined for the
@@ -42,3 +46,23 @@ func mustGetStringSliceFlag(cmd *cobra.Command, name string) []string {
 	}
 	return v
 }
+
+// mustGetStringArrayFlag gets an string array flag value from cmd, and panics
+// if this results in an error.
+func mustGetStringArrayFlag(cmd *cobra.Command, name string) []string {
+	v, err := cmd.Flags().GetStringArray(name)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// mustGetDurationFlag gets a duration flag value from cmd, and panics if this
+// results in an error.
+func mustGetDurationFlag(cmd *cobra.Command, name string) time.Duration {
+	v, err := cmd.Flags().GetDuration(name)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
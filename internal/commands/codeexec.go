@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/genai"
+)
+
+// addCodeExecFlags adds the flag enabling the model's code execution tool to
+// cmd.
+func addCodeExecFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("code-exec", false, "let the model write and run Python code to answer, with its built-in code execution tool")
+}
+
+// modelTools returns the tools the model named modelName can use according
+// to the flags of cmd: the local tools of tr (which may be nil), the code
+// execution tool with --code-exec, and Google Search with --grounding.
+func modelTools(cmd *cobra.Command, modelName string, tr *toolRunner) []*genai.Tool {
+	var tools []*genai.Tool
+	if tr != nil {
+		tools = tr.declarations()
+	}
+	if mustGetBoolFlag(cmd, "code-exec") {
+		tools = append(tools, &genai.Tool{CodeExecution: &genai.ToolCodeExecution{}})
+	}
+	if mustGetBoolFlag(cmd, "grounding") {
+		tools = append(tools, googleSearchTool(modelName))
+	}
+	return tools
+}
+
+// formatPart returns the text to print for a response part. Code run by the
+// model with the code execution tool and its result are formatted as
+// labelled Markdown code blocks.
+func formatPart(part *genai.Part) string {
+	switch {
+	case part.ExecutableCode != nil:
+		p := part.ExecutableCode
+		lang := strings.ToLower(trimEnumName(p.Language, "LANGUAGE_"))
+		return fmt.Sprintf("[executed code]\n```%s\n%s\n```", lang, strings.TrimRight(p.Code, "\n"))
+	case part.CodeExecutionResult != nil:
+		p := part.CodeExecutionResult
+		outcome := apiEnumName(p.Outcome, "OUTCOME_")
+		return fmt.Sprintf("[execution result: %s]\n```\n%s\n```", outcome, strings.TrimRight(p.Output, "\n"))
+	case isTextPart(part):
+		return part.Text
+	default:
+		b, _ := json.Marshal(part)
+		return string(b)
+	}
+}
+
+// candidateOutput returns the text of c along with the code it ran and the
+// results (formatted with formatPart), in order. Unlike candidateText, it's
+// meant for people rather than for parsing the response.
+func candidateOutput(c *genai.Candidate) string {
+	if c.Content == nil {
+		return ""
+	}
+	var sb strings.Builder
+	for _, part := range c.Content.Parts {
+		switch {
+		case part.ExecutableCode != nil || part.CodeExecutionResult != nil:
+			// Code blocks start on a line of their own.
+			if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+				sb.WriteString("\n")
+			}
+			sb.WriteString(formatPart(part) + "\n")
+		case isTextPart(part):
+			sb.WriteString(part.Text)
+		}
+	}
+	return sb.String()
+}
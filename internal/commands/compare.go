@@ -0,0 +1,225 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eliben/gemini-cli/internal/columns"
+	"github.com/eliben/gemini-cli/internal/templates"
+	"github.com/spf13/cobra"
+	"google.golang.org/genai"
+)
+
+// addCompareFlags adds the flags for sending a prompt to several models to
+// cmd.
+func addCompareFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSlice("models", nil, "send the prompt to each of these comma-separated models concurrently, and show their responses together")
+	cmd.Flags().String("layout", "sections", `layout of responses with --models: "sections" (one after another, with headers) or "columns" (next to each other)`)
+}
+
+// compareModelNames returns the model names set with --models in cmd, or nil
+// if it isn't set. It checks that the other flags of cmd can be used with
+// --models.
+func compareModelNames(cmd *cobra.Command) []string {
+	layout := mustGetStringFlag(cmd, "layout")
+	if layout != "sections" && layout != "columns" {
+		log.Fatalf("expect --layout to be sections or columns, got %q", layout)
+	}
+
+	names, err := cmd.Flags().GetStringSlice("models")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	for _, name := range names {
+		if strings.TrimSpace(name) == "" {
+			log.Fatal("expect --models to be a comma-separated list of model names")
+		}
+	}
+	for _, flag := range []string{"model", "tools", "cache", "extract", "extract-lang", "extract-to", "cached-content"} {
+		if cmd.Flags().Changed(flag) {
+			log.Fatalf("--models can't be used with --%s", flag)
+		}
+	}
+	if mustGetStringFlag(cmd, "stream-format") != "text" {
+		log.Fatal("--models can't be used with --stream-format jsonl")
+	}
+	return names
+}
+
+// compareResult is the response of one of the models a prompt is sent to
+// with --models.
+type compareResult struct {
+	modelName string
+	model     *generativeModel
+
+	// parts are the parts sent to the model; they may have been truncated
+	// to fit its token budget.
+	parts   []*genai.Part
+	resp    *genai.GenerateContentResponse
+	latency time.Duration
+	err     error
+}
+
+// runCompare sends parts to each of the models named in modelNames
+// concurrently, with the system instruction system and after the given chat
+// history (if any), and prints their responses in the output format (text or
+// json) and --layout of cmd, and their usage with usage. It exits with an
+// error status if any of the requests failed.
+func runCompare(ctx context.Context, cmd *cobra.Command, client *genai.Client, t *templates.Template, modelNames []string, system string, history []*genai.Content, parts []*genai.Part, budget *tokenBudget, outputFormat string, usage *usageMeter) {
+	results := make([]*compareResult, len(modelNames))
+	var wg sync.WaitGroup
+	for i, name := range modelNames {
+		model, schema := newNamedPromptModel(cmd, client, t, name)
+		applySystemInstruction(model, system)
+		model.Tools = modelTools(cmd, name, nil)
+		r := &compareResult{modelName: name, model: model, parts: parts}
+		results[i] = r
+
+		// Each model has its own token limit, so it needs its own budget.
+		var mb *tokenBudget
+		if budget != nil {
+			b := *budget
+			mb = &b
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if r.parts, r.err = mb.check(ctx, model, history, parts); r.err != nil {
+				return
+			}
+			start := time.Now()
+			if history != nil {
+				session := model.startChat()
+				// The sessions of all the models share history; clipping it
+				// makes each copy it when adding its turns.
+				session.History = slices.Clip(history)
+				r.resp, r.err = session.sendMessage(ctx, r.parts...)
+			} else {
+				r.resp, r.err = model.generateContent(ctx, r.parts...)
+			}
+			r.latency = time.Since(start)
+			if r.err == nil && schema != nil {
+				if err := checkResponseSchema(schema, r.resp); err != nil {
+					r.err = fmt.Errorf("response doesn't match schema: %w", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	logger := newExchangeLogger(cmd, false)
+	numFailed := 0
+	for _, r := range results {
+		if r.resp != nil {
+			logger.log(r.modelName, r.model.SystemInstruction, history, r.parts, r.resp, r.latency)
+		}
+		if r.err != nil {
+			numFailed++
+		}
+	}
+	logger.Close()
+
+	switch {
+	case outputFormat == "json":
+		envs := make([]*compareEnvelope, len(results))
+		for i, r := range results {
+			envs[i] = r.envelope()
+		}
+		if err := emitJSON(os.Stdout, envs); err != nil {
+			log.Fatal(err)
+		}
+	case mustGetStringFlag(cmd, "layout") == "columns":
+		width := terminalWidth(os.Stdout)
+		if width <= 0 {
+			width = 80
+		}
+		texts := make([]string, len(results))
+		for i, r := range results {
+			texts[i] = r.modelName + "\n" + r.summary() + "\n\n" + r.body()
+		}
+		fmt.Print(columns.Format(texts, width))
+	default:
+		for i, r := range results {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("=== %s (%s) ===\n", r.modelName, r.summary())
+			fmt.Print(r.body())
+		}
+	}
+
+	for _, r := range results {
+		if r.resp != nil {
+			usage.report(r.modelName, responseUsage(r.resp))
+		}
+	}
+
+	if numFailed > 0 {
+		log.Fatalf("%d of %d models failed", numFailed, len(results))
+	}
+}
+
+// summary returns a one-line summary of r: its latency and token usage.
+func (r *compareResult) summary() string {
+	if r.err != nil && r.resp == nil {
+		return "failed"
+	}
+	s := r.latency.Round(time.Millisecond).String()
+	if r.resp.UsageMetadata != nil {
+		u := r.resp.UsageMetadata
+		s += fmt.Sprintf(", %d prompt + %d response tokens", u.PromptTokenCount, u.CandidatesTokenCount)
+	}
+	if r.err != nil {
+		s += ", failed"
+	}
+	return s
+}
+
+// body returns the text of the response of r, with its finish reasons, or
+// a description of its error.
+func (r *compareResult) body() string {
+	var sb strings.Builder
+	if r.resp != nil {
+		printCandidates(&sb, r.resp)
+		reportFinishReasons(&sb, r.resp)
+	}
+	if r.err != nil {
+		var blocked *blockedError
+		if errors.As(r.err, &blocked) {
+			reportBlocked(&sb, blocked)
+		} else {
+			fmt.Fprintf(&sb, "error: %v\n", r.err)
+		}
+	}
+	return sb.String()
+}
+
+// compareEnvelope is the JSON representation of a compareResult: the
+// response of one of the models, or the error it failed with.
+type compareEnvelope struct {
+	*responseEnvelope
+	Error string `json:"error,omitempty"`
+}
+
+func (r *compareResult) envelope() *compareEnvelope {
+	var blocked *blockedError
+	if errors.As(r.err, &blocked) {
+		return &compareEnvelope{newBlockedResponseEnvelope(r.modelName, r.model, blocked, r.latency), r.err.Error()}
+	}
+	env := &compareEnvelope{responseEnvelope: newResponseEnvelope(r.modelName, r.model, r.resp, r.latency)}
+	if r.err != nil {
+		env.Error = r.err.Error()
+	}
+	return env
+}
@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/eliben/gemini-cli/internal/conversation"
+	"github.com/spf13/cobra"
+	"google.golang.org/genai"
+)
+
+// addConversationFlags adds the flag for sending a prompt after the turns of
+// a conversation file to cmd.
+func addConversationFlags(cmd *cobra.Command) {
+	cmd.Flags().String("conversation", "", "path of a YAML file with a list of user and model turns to send as the history before the prompt")
+}
+
+// conversationHistory loads the conversation file set with --conversation
+// in cmd, and returns its turns as chat history; files are sent with
+// uploader and URLs fetched with fetcher. It returns nil if --conversation
+// isn't set.
+func conversationHistory(ctx context.Context, cmd *cobra.Command, uploader *fileUploader, fetcher *urlFetcher) ([]*genai.Content, error) {
+	path := mustGetStringFlag(cmd, "conversation")
+	if path == "" {
+		return nil, nil
+	}
+	turns, err := conversation.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var history []*genai.Content
+	for i, turn := range turns {
+		content := &genai.Content{Role: turn.Role}
+		for _, p := range turn.Parts {
+			var part *genai.Part
+			var err error
+			switch {
+			case p.File != "":
+				part, err = uploader.partFromFile(ctx, p.File)
+			case p.URL != "":
+				part, err = fetcher.part(ctx, p.URL)
+			default:
+				part = genai.NewPartFromText(p.Text)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("conversation %s, turn %d: %w", path, i+1, err)
+			}
+			content.Parts = append(content.Parts, part)
+		}
+		history = append(history, content)
+	}
+	return history, nil
+}
+
+// splitLastUserTurn returns history without its last turn and the parts of
+// that turn, if it's a user turn; the parts of the prompt are sent in the
+// same turn, after them. Otherwise, it returns history as is.
+func splitLastUserTurn(history []*genai.Content) ([]*genai.Content, []*genai.Part) {
+	n := len(history)
+	if n == 0 || history[n-1].Role != conversation.RoleUser {
+		return history, nil
+	}
+	return history[:n-1], history[n-1].Parts
+}
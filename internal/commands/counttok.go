@@ -7,8 +7,8 @@ import (
 	"log"
 	"strings"
 
-	"github.com/google/generative-ai-go/genai"
 	"github.com/spf13/cobra"
+	"google.golang.org/genai"
 )
 
 var countTokCmd = &cobra.Command{
@@ -45,13 +45,13 @@ func runCountTokCmd(cmd *cobra.Command, args []string) {
 	ctx := context.Background()
 	client, err := newGenaiClient(ctx, cmd)
 	if err != nil {
-		log.Fatal()
+		log.Fatal(err)
 	}
 
-	model := client.GenerativeModel(mustGetStringFlag(cmd, "model"))
-	resp, err := model.CountTokens(ctx, genai.Text(content))
+	model := newGenerativeModel(client, mustGetStringFlag(cmd, "model"))
+	tokens, err := model.countTokens(ctx, genai.NewContentFromText(content, genai.RoleUser))
 	if err != nil {
 		log.Fatal("error counting tokens:", err)
 	}
-	fmt.Println(resp.TotalTokens)
+	fmt.Println(tokens)
 }
@@ -10,8 +10,8 @@ import (
 	"os"
 	"strings"
 
-	"github.com/google/generative-ai-go/genai"
 	"github.com/spf13/cobra"
+	"google.golang.org/genai"
 )
 
 var embedContentCmd = &cobra.Command{
@@ -34,6 +34,7 @@ included), or read from standard input if '-' is provided.
 func init() {
 	embedCmd.AddCommand(embedContentCmd)
 	embedContentCmd.Flags().String("format", "json", "format for embedding output: json, base64, blob")
+	addUsageFlags(embedContentCmd)
 }
 
 func runEmbedContentCmd(cmd *cobra.Command, args []string) {
@@ -47,20 +48,22 @@ func runEmbedContentCmd(cmd *cobra.Command, args []string) {
 		content = string(b)
 	}
 
+	usage := newUsageMeter(cmd)
 	ctx := context.Background()
 	client, err := newGenaiClient(ctx, cmd)
 	if err != nil {
-		log.Fatal()
+		log.Fatal(err)
 	}
 
-	model := client.EmbeddingModel(mustGetStringFlag(cmd, "model"))
-	res, err := model.EmbedContent(ctx, genai.Text(content))
+	modelName := mustGetStringFlag(cmd, "model")
+	res, err := client.Models.EmbedContent(ctx, modelName, genai.Text(content), nil)
 	if err != nil {
 		log.Fatal("error embedding content:", err)
 	}
+	usage.reportEmbedding(modelName, res)
 
-	if emb := res.Embedding; emb != nil {
-		emitEmbedding(os.Stdout, emb.Values, mustGetStringFlag(cmd, "format"))
+	if len(res.Embeddings) > 0 && res.Embeddings[0] != nil {
+		emitEmbedding(os.Stdout, res.Embeddings[0].Values, mustGetStringFlag(cmd, "format"))
 	} else {
 		log.Fatal("got no embedding back from model")
 	}
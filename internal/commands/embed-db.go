@@ -14,8 +14,8 @@ import (
 	"strings"
 
 	"github.com/eliben/gemini-cli/internal/tableloader"
-	"github.com/google/generative-ai-go/genai"
 	"github.com/spf13/cobra"
+	"google.golang.org/genai"
 )
 
 var embedDBCmd = &cobra.Command{
@@ -64,6 +64,7 @@ picking all the files that match the glob`))
 	embedDBCmd.Flags().String("metadata", "", `also store this metadata in the embeddings table ('metadata' column)`)
 	embedDBCmd.Flags().String("prefix", "", `prepend a prefix to the stored ID of each row`)
 	embedDBCmd.Flags().String("id-conflict", "error", `what to do when inserting IDs that already exist: "error", "replace" or "skip"`)
+	addUsageFlags(embedDBCmd)
 }
 
 func runEmbedDBCmd(cmd *cobra.Command, args []string) {
@@ -202,13 +203,13 @@ func runEmbedDBCmd(cmd *cobra.Command, args []string) {
 	}
 	log.Printf("Found %d values to embed", len(texts))
 
+	usage := newUsageMeter(cmd)
 	ctx := context.Background()
 	client, err := newGenaiClient(ctx, cmd)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer client.Close()
-	em := client.EmbeddingModel(mustGetStringFlag(cmd, "model"))
+	modelName := mustGetStringFlag(cmd, "model")
 
 	batchSize := mustGetIntFlag(cmd, "batch-size")
 	numBatches := len(texts) / batchSize
@@ -222,20 +223,19 @@ func runEmbedDBCmd(cmd *cobra.Command, args []string) {
 	cursor := 0
 	embs := make([][]float32, 0, len(texts))
 	for bn := 0; bn < numBatches; bn++ {
-		batch := em.NewBatch()
-
 		sizeOfThisBatch := batchSize
 		if cursor+batchSize >= len(texts) {
 			sizeOfThisBatch = len(texts) - cursor
 		}
 		log.Printf("Embedding batch #%d / %d, size=%d", bn+1, numBatches, sizeOfThisBatch)
 
+		var batch []*genai.Content
 		for i := 0; i < sizeOfThisBatch; i++ {
-			batch.AddContent(genai.Text(texts[cursor]))
+			batch = append(batch, genai.NewContentFromText(texts[cursor], genai.RoleUser))
 			cursor++
 		}
 
-		res, err := em.BatchEmbedContents(ctx, batch)
+		res, err := client.Models.EmbedContent(ctx, modelName, batch, nil)
 		if err != nil {
 			log.Fatalf("error embedding batch %d: %v", bn, err)
 		}
@@ -247,7 +247,10 @@ func runEmbedDBCmd(cmd *cobra.Command, args []string) {
 		for _, e := range res.Embeddings {
 			embs = append(embs, e.Values)
 		}
+
+		usage.addEmbedding(modelName, res)
 	}
+	usage.reportTotals()
 
 	log.Printf("Collected %d embeddings; inserting into table %s", len(embs), tableName)
 
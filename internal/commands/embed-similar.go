@@ -12,8 +12,8 @@ import (
 	"strings"
 
 	"github.com/chewxy/math32"
-	"github.com/google/generative-ai-go/genai"
 	"github.com/spf13/cobra"
+	"google.golang.org/genai"
 )
 
 var embedSimilarCmd = &cobra.Command{
@@ -42,6 +42,7 @@ func init() {
 	embedCmd.AddCommand(embedSimilarCmd)
 	embedSimilarCmd.Flags().Int("topk", 5, "top K: how many most similar entries to return")
 	embedSimilarCmd.Flags().StringSlice("show", []string{"id", "score"}, "the columns to emit for the most similar DB entries")
+	addUsageFlags(embedSimilarCmd)
 }
 
 func runEmbedSimilarCmd(cmd *cobra.Command, args []string) {
@@ -58,21 +59,23 @@ func runEmbedSimilarCmd(cmd *cobra.Command, args []string) {
 	}
 
 	// Calculate the content's embedding vector
+	usage := newUsageMeter(cmd)
 	ctx := context.Background()
 	client, err := newGenaiClient(ctx, cmd)
 	if err != nil {
-		log.Fatal()
+		log.Fatal(err)
 	}
 
-	model := client.EmbeddingModel(mustGetStringFlag(cmd, "model"))
-	res, err := model.EmbedContent(ctx, genai.Text(content))
+	modelName := mustGetStringFlag(cmd, "model")
+	res, err := client.Models.EmbedContent(ctx, modelName, genai.Text(content), nil)
 	if err != nil {
 		log.Fatal("error embedding content:", err)
 	}
+	usage.reportEmbedding(modelName, res)
 
 	var contentEmb []float32
-	if emb := res.Embedding; emb != nil {
-		contentEmb = emb.Values
+	if len(res.Embeddings) > 0 && res.Embeddings[0] != nil {
+		contentEmb = res.Embeddings[0].Values
 	} else {
 		log.Fatal("got no embedding back from model")
 	}
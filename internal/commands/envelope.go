@@ -0,0 +1,242 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// responseEnvelope is the machine-readable form of a model's response,
+// emitted by 'prompt --output json'. It carries everything a wrapper script
+// may want to know about the response besides its text.
+type responseEnvelope struct {
+	Model          string                  `json:"model"`
+	Candidates     []candidateEnvelope     `json:"candidates"`
+	PromptFeedback *promptFeedbackEnvelope `json:"prompt_feedback,omitempty"`
+	Usage          *usageEnvelope          `json:"usage,omitempty"`
+	LatencyMs      int64                   `json:"latency_ms"`
+	Params         generationParams        `json:"params"`
+
+	// Cached is set when the response was taken from the response cache.
+	Cached bool `json:"cached,omitempty"`
+}
+
+type candidateEnvelope struct {
+	Index         int32                  `json:"index"`
+	Text          []string               `json:"text"`
+	FinishReason  string                 `json:"finish_reason"`
+	SafetyRatings []safetyRatingEnvelope `json:"safety_ratings,omitempty"`
+
+	// CodeExecution has the code the model ran with the code execution tool,
+	// and the results, in order.
+	CodeExecution []codeExecutionEnvelope `json:"code_execution,omitempty"`
+	Citations     []citationEnvelope      `json:"citations,omitempty"`
+	Grounding     *groundingEnvelope      `json:"grounding,omitempty"`
+}
+
+// codeExecutionEnvelope is code run by the model (with Type
+// "executable_code") or its result (with Type "code_execution_result").
+type codeExecutionEnvelope struct {
+	Type     string `json:"type"`
+	Language string `json:"language,omitempty"`
+	Code     string `json:"code,omitempty"`
+	Outcome  string `json:"outcome,omitempty"`
+	Output   string `json:"output,omitempty"`
+}
+
+type citationEnvelope struct {
+	URI        string `json:"uri,omitempty"`
+	License    string `json:"license,omitempty"`
+	StartIndex int32  `json:"start_index"`
+	EndIndex   int32  `json:"end_index"`
+}
+
+type promptFeedbackEnvelope struct {
+	BlockReason   string                 `json:"block_reason"`
+	SafetyRatings []safetyRatingEnvelope `json:"safety_ratings,omitempty"`
+}
+
+type safetyRatingEnvelope struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked,omitempty"`
+}
+
+type usageEnvelope struct {
+	PromptTokens     int32 `json:"prompt_tokens"`
+	CandidatesTokens int32 `json:"candidates_tokens"`
+	CachedTokens     int32 `json:"cached_tokens"`
+	TotalTokens      int32 `json:"total_tokens"`
+}
+
+// generationParams are the generation parameters a request was sent with.
+// Parameters that weren't set (leaving the model's defaults) are omitted.
+type generationParams struct {
+	Temperature      *float32          `json:"temperature,omitempty"`
+	TopP             *float32          `json:"top_p,omitempty"`
+	TopK             *int32            `json:"top_k,omitempty"`
+	MaxOutputTokens  *int32            `json:"max_output_tokens,omitempty"`
+	CandidateCount   *int32            `json:"candidate_count,omitempty"`
+	StopSequences    []string          `json:"stop_sequences,omitempty"`
+	Seed             *int32            `json:"seed,omitempty"`
+	ResponseMIMEType string            `json:"response_mime_type,omitempty"`
+	Safety           map[string]string `json:"safety,omitempty"`
+}
+
+// newResponseEnvelope creates an envelope for resp, which was generated by
+// model (named modelName) in the given latency.
+func newResponseEnvelope(modelName string, model *generativeModel, resp *genai.GenerateContentResponse, latency time.Duration) *responseEnvelope {
+	env := &responseEnvelope{
+		Model:      modelName,
+		Candidates: []candidateEnvelope{},
+		LatencyMs:  latency.Milliseconds(),
+		Params:     newGenerationParams(model),
+	}
+	if resp == nil {
+		return env
+	}
+
+	for _, c := range resp.Candidates {
+		env.Candidates = append(env.Candidates, newCandidateEnvelope(c))
+	}
+	if pf := resp.PromptFeedback; pf != nil {
+		env.PromptFeedback = &promptFeedbackEnvelope{
+			BlockReason:   apiEnumName(pf.BlockReason, "BLOCKED_REASON_"),
+			SafetyRatings: newSafetyRatingEnvelopes(pf.SafetyRatings),
+		}
+	}
+	if um := resp.UsageMetadata; um != nil {
+		env.Usage = &usageEnvelope{
+			PromptTokens:     um.PromptTokenCount,
+			CandidatesTokens: um.CandidatesTokenCount,
+			CachedTokens:     um.CachedContentTokenCount,
+			TotalTokens:      um.TotalTokenCount,
+		}
+	}
+	return env
+}
+
+// newBlockedResponseEnvelope creates an envelope for a request that failed
+// because the prompt or the response was blocked.
+func newBlockedResponseEnvelope(modelName string, model *generativeModel, blocked *blockedError, latency time.Duration) *responseEnvelope {
+	resp := &genai.GenerateContentResponse{PromptFeedback: blocked.PromptFeedback}
+	if blocked.Candidate != nil {
+		resp.Candidates = []*genai.Candidate{blocked.Candidate}
+	}
+	return newResponseEnvelope(modelName, model, resp, latency)
+}
+
+func newCandidateEnvelope(c *genai.Candidate) candidateEnvelope {
+	ce := candidateEnvelope{
+		Index:         c.Index,
+		Text:          []string{},
+		FinishReason:  apiEnumName(c.FinishReason, "FINISH_REASON_"),
+		SafetyRatings: newSafetyRatingEnvelopes(c.SafetyRatings),
+		Grounding:     newGroundingEnvelope(c.GroundingMetadata),
+	}
+	if c.Content != nil {
+		for _, part := range c.Content.Parts {
+			switch {
+			case part.ExecutableCode != nil || part.CodeExecutionResult != nil:
+				ce.CodeExecution = append(ce.CodeExecution, newCodeExecutionEnvelope(part))
+			case isTextPart(part):
+				ce.Text = append(ce.Text, part.Text)
+			}
+		}
+	}
+	if cm := c.CitationMetadata; cm != nil {
+		for _, src := range cm.Citations {
+			ce.Citations = append(ce.Citations, newCitationEnvelope(src))
+		}
+	}
+	return ce
+}
+
+// newCodeExecutionEnvelope returns the envelope of part, which has
+// executable code or a code execution result.
+func newCodeExecutionEnvelope(part *genai.Part) codeExecutionEnvelope {
+	switch {
+	case part.ExecutableCode != nil:
+		return codeExecutionEnvelope{
+			Type:     "executable_code",
+			Language: apiEnumName(part.ExecutableCode.Language, "LANGUAGE_"),
+			Code:     part.ExecutableCode.Code,
+		}
+	case part.CodeExecutionResult != nil:
+		return codeExecutionEnvelope{
+			Type:    "code_execution_result",
+			Outcome: apiEnumName(part.CodeExecutionResult.Outcome, "OUTCOME_"),
+			Output:  part.CodeExecutionResult.Output,
+		}
+	}
+	panic(fmt.Sprintf("unexpected code execution part %+v", part))
+}
+
+func newCitationEnvelope(src *genai.Citation) citationEnvelope {
+	return citationEnvelope{
+		URI:        src.URI,
+		License:    src.License,
+		StartIndex: src.StartIndex,
+		EndIndex:   src.EndIndex,
+	}
+}
+
+func newSafetyRatingEnvelopes(ratings []*genai.SafetyRating) []safetyRatingEnvelope {
+	var envs []safetyRatingEnvelope
+	for _, r := range ratings {
+		envs = append(envs, safetyRatingEnvelope{
+			Category:    apiEnumName(r.Category, "HARM_CATEGORY_"),
+			Probability: apiEnumName(r.Probability, "HARM_PROBABILITY_"),
+			Blocked:     r.Blocked,
+		})
+	}
+	return envs
+}
+
+func newGenerationParams(model *generativeModel) generationParams {
+	params := generationParams{
+		Temperature:      model.Temperature,
+		TopP:             model.TopP,
+		StopSequences:    model.StopSequences,
+		Seed:             model.Seed,
+		ResponseMIMEType: model.ResponseMIMEType,
+	}
+	if model.TopK != nil {
+		topK := int32(*model.TopK)
+		params.TopK = &topK
+	}
+	if n := model.MaxOutputTokens; n > 0 {
+		params.MaxOutputTokens = &n
+	}
+	if n := model.CandidateCount; n > 0 {
+		params.CandidateCount = &n
+	}
+	if len(model.SafetySettings) > 0 {
+		params.Safety = make(map[string]string)
+		for _, ss := range model.SafetySettings {
+			params.Safety[apiEnumName(ss.Category, "HARM_CATEGORY_")] = apiEnumName(ss.Threshold, "BLOCK_")
+		}
+	}
+	return params
+}
+
+// emitJSON writes v to w as indented JSON.
+func emitJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// apiEnumName returns the name of a genai enum value as the Gemini API
+// names it, without the prefix of its type's values (like "HARM_CATEGORY_"
+// in "HARM_CATEGORY_HARASSMENT"). An unset value is "UNSPECIFIED".
+func apiEnumName[E ~string](v E, prefix string) string {
+	if v == "" {
+		return "UNSPECIFIED"
+	}
+	return strings.TrimPrefix(string(v), prefix)
+}
@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/eliben/gemini-cli/internal/codeblocks"
+	"github.com/spf13/cobra"
+	"google.golang.org/genai"
+)
+
+// addExtractFlags adds the flags for extracting code blocks from responses
+// to cmd.
+func addExtractFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("extract", false, "print only the fenced code blocks in the response")
+	cmd.Flags().String("extract-lang", "", "with --extract, only extract code blocks in this language (like go or python); implies --extract")
+	cmd.Flags().String("extract-to", "", "with --extract, write each code block to a file in this directory instead of printing it; implies --extract")
+}
+
+// codeExtractor extracts the fenced code blocks from responses.
+type codeExtractor struct {
+	// lang is the (normalized) language of blocks to extract; all blocks are
+	// extracted if it's empty.
+	lang string
+
+	// dir is the directory to write blocks to; they're printed if it's
+	// empty.
+	dir string
+}
+
+// newCodeExtractor creates a codeExtractor according to the flags of cmd,
+// or returns nil if code blocks aren't extracted.
+func newCodeExtractor(cmd *cobra.Command) *codeExtractor {
+	lang := mustGetStringFlag(cmd, "extract-lang")
+	dir := mustGetStringFlag(cmd, "extract-to")
+	if !mustGetBoolFlag(cmd, "extract") && lang == "" && dir == "" {
+		return nil
+	}
+	return &codeExtractor{lang: codeblocks.NormalizeLang(lang), dir: dir}
+}
+
+// blocks returns the code blocks to extract from resp. Only the first
+// candidate is used.
+func (ce *codeExtractor) blocks(resp *genai.GenerateContentResponse) ([]codeblocks.Block, error) {
+	if len(resp.Candidates) < 1 {
+		return nil, errors.New("empty response from model")
+	}
+	var blocks []codeblocks.Block
+	for _, b := range codeblocks.Extract(candidateOutput(resp.Candidates[0])) {
+		if ce.lang == "" || b.Lang == ce.lang {
+			blocks = append(blocks, b)
+		}
+	}
+	if len(blocks) == 0 {
+		if ce.lang != "" {
+			return nil, fmt.Errorf("no %s code blocks in the response", ce.lang)
+		}
+		return nil, errors.New("no code blocks in the response")
+	}
+	return blocks, nil
+}
+
+// extract extracts the code blocks from resp. They're printed to w,
+// separated by empty lines, or written to files in ce.dir, in which case the
+// paths of the files are printed to w.
+func (ce *codeExtractor) extract(w io.Writer, resp *genai.GenerateContentResponse) error {
+	blocks, err := ce.blocks(resp)
+	if err != nil {
+		return err
+	}
+
+	if ce.dir == "" {
+		for i, b := range blocks {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprint(w, b.Code)
+		}
+		return nil
+	}
+
+	for i, b := range blocks {
+		name := blockFileName(b)
+		if name == "" {
+			name = fmt.Sprintf("block-%d%s", i+1, codeblocks.Extension(b.Lang))
+		}
+		path := filepath.Join(ce.dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(b.Code), 0o644); err != nil {
+			return err
+		}
+		fmt.Fprintln(w, path)
+	}
+	return nil
+}
+
+// blockFileName returns the file name hinted for b, relative to the
+// directory blocks are written to, or "" if b has no usable hint. Hints
+// outside the directory (absolute paths, or paths with "..") are ignored.
+func blockFileName(b codeblocks.Block) string {
+	if b.Path == "" {
+		return ""
+	}
+	name := filepath.FromSlash(b.Path)
+	if !filepath.IsLocal(name) {
+		fmt.Fprintf(os.Stderr, "warning: ignoring file path %q of code block outside the --extract-to directory\n", b.Path)
+		return ""
+	}
+	return filepath.Clean(name)
+}
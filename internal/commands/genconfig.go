@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/genai"
+)
+
+// addGenerationFlags adds the flags controlling the model's generation config
+// and safety settings to cmd. These flags are applied to a model with
+// applyGenerationFlags.
+func addGenerationFlags(cmd *cobra.Command) {
+	// The temperature setting is a string because we want to set it only if
+	// the user provided it explicitly, keeping the model's default otherwise.
+	cmd.Flags().String("temp", "", "temperature setting for the model")
+
+	// For the other settings, the model's defaults are kept unless the flag
+	// was explicitly set on the command-line.
+	cmd.Flags().Int32("max-tokens", 0, "maximal number of tokens to generate in a response")
+	cmd.Flags().Float32("top-p", 0, "top-p (nucleus sampling) setting for the model")
+	cmd.Flags().Int32("top-k", 0, "top-k sampling setting for the model")
+	cmd.Flags().StringArray("stop", nil, "stop sequence for generation; can be repeated")
+	cmd.Flags().Int32("seed", 0, "seed for sampling, to make responses to the same request more reproducible")
+
+	addSafetyFlags(cmd)
+}
+
+// applyGenerationFlags sets the generation config and safety settings of
+// model according to the flags added with addGenerationFlags (and the
+// optional 'candidates' flag).
+func applyGenerationFlags(cmd *cobra.Command, model *generativeModel) {
+	if tempValue := mustGetStringFlag(cmd, "temp"); tempValue != "" {
+		f, err := strconv.ParseFloat(tempValue, 32)
+		if err != nil {
+			log.Fatalf("problem parsing --temp value: %v", err)
+		}
+		model.Temperature = genai.Ptr(float32(f))
+	}
+
+	flags := cmd.Flags()
+	if flags.Changed("max-tokens") {
+		v, _ := flags.GetInt32("max-tokens")
+		model.MaxOutputTokens = v
+	}
+	if flags.Changed("top-p") {
+		v, _ := flags.GetFloat32("top-p")
+		model.TopP = genai.Ptr(v)
+	}
+	if flags.Changed("top-k") {
+		v, _ := flags.GetInt32("top-k")
+		model.TopK = genai.Ptr(float32(v))
+	}
+	if stops, _ := flags.GetStringArray("stop"); len(stops) > 0 {
+		model.StopSequences = stops
+	}
+	if flags.Changed("seed") {
+		v, _ := flags.GetInt32("seed")
+		model.Seed = genai.Ptr(v)
+	}
+	if flags.Lookup("candidates") != nil && flags.Changed("candidates") {
+		v, _ := flags.GetInt32("candidates")
+		if v < 1 {
+			log.Fatalf("expect --candidates to be at least 1, got %d", v)
+		}
+		model.CandidateCount = v
+	}
+
+	applySafetyFlags(cmd, model)
+}
@@ -0,0 +1,138 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/genai"
+)
+
+// addGroundingFlags adds the flag enabling grounding in Google Search to cmd.
+func addGroundingFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("grounding", false, "ground responses in Google Search results, which are listed as numbered sources after the response")
+}
+
+// googleSearchTool returns the tool grounding the responses of the model
+// named modelName in Google Search results. Gemini 1.5 models search with
+// the Google Search retrieval tool, and later models with the Google Search
+// tool.
+func googleSearchTool(modelName string) *genai.Tool {
+	if strings.HasPrefix(strings.TrimPrefix(modelName, "models/"), "gemini-1.") {
+		return &genai.Tool{GoogleSearchRetrieval: &genai.GoogleSearchRetrieval{}}
+	}
+	return &genai.Tool{GoogleSearch: &genai.GoogleSearch{}}
+}
+
+// groundedText returns text, the text of the part at partIndex of a
+// candidate grounded with gm, with the numbers of the sources supporting
+// each segment of it (as printed by printGroundingSources) inserted after
+// the segment, like "[1][3]".
+func groundedText(text string, partIndex int, gm *genai.GroundingMetadata) string {
+	if gm == nil {
+		return text
+	}
+	markers := make(map[int]string)
+	for _, s := range gm.GroundingSupports {
+		if s.Segment == nil || int(s.Segment.PartIndex) != partIndex {
+			continue
+		}
+		// Segments are delimited by byte offsets in the text of the part.
+		end := int(s.Segment.EndIndex)
+		if end <= 0 || end > len(text) || (end < len(text) && !utf8.RuneStart(text[end])) {
+			continue
+		}
+		for _, i := range s.GroundingChunkIndices {
+			markers[end] += fmt.Sprintf("[%d]", i+1)
+		}
+	}
+	if len(markers) == 0 {
+		return text
+	}
+
+	var sb strings.Builder
+	prev := 0
+	for _, end := range slices.Sorted(maps.Keys(markers)) {
+		sb.WriteString(text[prev:end])
+		sb.WriteString(markers[end])
+		prev = end
+	}
+	sb.WriteString(text[prev:])
+	return sb.String()
+}
+
+// printGroundingSources prints the web sources of the Google Search results
+// a candidate was grounded in to w, numbered in the order of gm (which
+// supports refer to them by).
+func printGroundingSources(w io.Writer, gm *genai.GroundingMetadata) {
+	if gm == nil || !slices.ContainsFunc(gm.GroundingChunks, func(c *genai.GroundingChunk) bool { return c.Web != nil }) {
+		return
+	}
+	fmt.Fprintln(w, "\nSources:")
+	for i, chunk := range gm.GroundingChunks {
+		if chunk.Web == nil {
+			continue
+		}
+		if chunk.Web.Title != "" {
+			fmt.Fprintf(w, "[%d] %s (%s)\n", i+1, chunk.Web.URI, chunk.Web.Title)
+		} else {
+			fmt.Fprintf(w, "[%d] %s\n", i+1, chunk.Web.URI)
+		}
+	}
+}
+
+// groundingEnvelope has the Google Search results a candidate was grounded
+// in: the searches the model ran, the sources it used, and the segments of
+// its text each source supports. Supports refer to sources by their number,
+// starting from 1.
+type groundingEnvelope struct {
+	SearchQueries []string                   `json:"search_queries,omitempty"`
+	Sources       []groundingSourceEnvelope  `json:"sources"`
+	Supports      []groundingSupportEnvelope `json:"supports,omitempty"`
+}
+
+type groundingSourceEnvelope struct {
+	URI   string `json:"uri"`
+	Title string `json:"title,omitempty"`
+}
+
+type groundingSupportEnvelope struct {
+	Text       string  `json:"text"`
+	StartIndex int32   `json:"start_index"`
+	EndIndex   int32   `json:"end_index"`
+	Sources    []int32 `json:"sources"`
+}
+
+// newGroundingEnvelope returns the envelope of gm, or nil if gm is nil.
+func newGroundingEnvelope(gm *genai.GroundingMetadata) *groundingEnvelope {
+	if gm == nil {
+		return nil
+	}
+	ge := &groundingEnvelope{SearchQueries: gm.WebSearchQueries, Sources: []groundingSourceEnvelope{}}
+	for _, chunk := range gm.GroundingChunks {
+		var src groundingSourceEnvelope
+		if chunk.Web != nil {
+			src = groundingSourceEnvelope{URI: chunk.Web.URI, Title: chunk.Web.Title}
+		}
+		ge.Sources = append(ge.Sources, src)
+	}
+	for _, s := range gm.GroundingSupports {
+		if s.Segment == nil {
+			continue
+		}
+		se := groundingSupportEnvelope{
+			Text:       s.Segment.Text,
+			StartIndex: s.Segment.StartIndex,
+			EndIndex:   s.Segment.EndIndex,
+		}
+		for _, i := range s.GroundingChunkIndices {
+			se.Sources = append(se.Sources, i+1)
+		}
+		ge.Supports = append(ge.Supports, se)
+	}
+	return ge
+}
@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/eliben/gemini-cli/internal/promptlog"
+	"github.com/spf13/cobra"
+	"google.golang.org/genai"
+)
+
+// addLogFlags adds the flags controlling the log of exchanges to cmd.
+func addLogFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("no-log", false, "don't record prompts and responses in the log (see the 'logs' command)")
+}
+
+// exchangeLogger records the exchanges of a command with the model in the
+// log. Failures to log are reported as warnings, and never fail the command.
+// A nil *exchangeLogger doesn't log anything.
+type exchangeLogger struct {
+	db           *promptlog.DB
+	command      string
+	conversation string
+}
+
+// newExchangeLogger creates a logger for the exchanges of cmd, unless logging
+// was disabled with --no-log (in which case it returns nil). If conversation
+// is true, all the exchanges logged are marked as belonging to the same chat
+// session.
+func newExchangeLogger(cmd *cobra.Command, conversation bool) *exchangeLogger {
+	if mustGetBoolFlag(cmd, "no-log") {
+		return nil
+	}
+
+	path, err := promptlog.DefaultPath()
+	if err != nil {
+		warnLogFailure(err)
+		return nil
+	}
+	db, err := promptlog.Open(path)
+	if err != nil {
+		warnLogFailure(err)
+		return nil
+	}
+
+	l := &exchangeLogger{db: db, command: cmd.Name()}
+	if conversation {
+		b := make([]byte, 8)
+		rand.Read(b)
+		l.conversation = hex.EncodeToString(b)
+	}
+	return l
+}
+
+// log records an exchange in which parts were sent to the model named
+// modelName with the system instruction system (which may be nil) after the
+// given chat history (if any), and the model responded with resp after the
+// given duration.
+func (l *exchangeLogger) log(modelName string, system *genai.Content, history []*genai.Content, parts []*genai.Part, resp *genai.GenerateContentResponse, duration time.Duration) {
+	if l == nil {
+		return
+	}
+
+	e := &promptlog.Entry{
+		Time:         time.Now().Add(-duration),
+		Command:      l.command,
+		Conversation: l.conversation,
+		Model:        modelName,
+		System:       systemText(system),
+		Duration:     duration,
+	}
+
+	var attachments []*promptlog.Attachment
+	for _, c := range history {
+		turnParts, turnAttachments := logParts(c.Parts)
+		e.History = append(e.History, promptlog.Turn{Role: c.Role, Parts: turnParts})
+		attachments = append(attachments, turnAttachments...)
+	}
+	var partAttachments []*promptlog.Attachment
+	e.Parts, partAttachments = logParts(parts)
+	attachments = append(attachments, partAttachments...)
+
+	if resp != nil {
+		if len(resp.Candidates) > 0 {
+			c := resp.Candidates[0]
+			e.Response = candidateOutput(c)
+			e.FinishReason = apiEnumName(c.FinishReason, "FINISH_REASON_")
+		}
+		if um := resp.UsageMetadata; um != nil {
+			e.Usage = promptlog.Usage{
+				PromptTokens:   um.PromptTokenCount,
+				ResponseTokens: um.CandidatesTokenCount + um.ThoughtsTokenCount,
+				CachedTokens:   um.CachedContentTokenCount,
+				TotalTokens:    um.TotalTokenCount,
+			}
+		}
+	}
+
+	if err := l.db.Add(e, attachments); err != nil {
+		warnLogFailure(err)
+	}
+}
+
+// logParts converts prompt parts to their form in the log, and returns them
+// with the attachments holding the data of binary parts.
+func logParts(parts []*genai.Part) ([]promptlog.Part, []*promptlog.Attachment) {
+	var logged []promptlog.Part
+	var attachments []*promptlog.Attachment
+	for _, part := range parts {
+		switch {
+		case part.InlineData != nil:
+			a := promptlog.NewAttachment(part.InlineData.MIMEType, part.InlineData.Data)
+			attachments = append(attachments, a)
+			logged = append(logged, a.Part())
+		case part.FileData != nil:
+			logged = append(logged, promptlog.Part{Type: "file", MIMEType: part.FileData.MIMEType, URI: part.FileData.FileURI})
+		case part.FunctionCall != nil:
+			logged = append(logged, promptlog.Part{Type: "function_call"})
+		case part.FunctionResponse != nil:
+			logged = append(logged, promptlog.Part{Type: "function_response"})
+		case isTextPart(part):
+			logged = append(logged, promptlog.Part{Type: "text", Text: part.Text})
+		default:
+			logged = append(logged, promptlog.Part{Type: "other"})
+		}
+	}
+	return logged, attachments
+}
+
+// Close closes the log.
+func (l *exchangeLogger) Close() {
+	if l != nil {
+		l.db.Close()
+	}
+}
+
+func warnLogFailure(err error) {
+	fmt.Fprintf(os.Stderr, "warning: unable to record exchange in the log: %v\n", err)
+}
@@ -0,0 +1,245 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/eliben/gemini-cli/internal/promptlog"
+	"github.com/spf13/cobra"
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Browse the log of prompts and responses",
+	Long:  strings.TrimSpace(logsUsage),
+
+	// 'logs' is a parent of subcommands, and doesn't do anything on its own.
+	// Therefore we don't define a Run: function for it.
+}
+
+var logsUsage = `
+Browse the log of prompts and responses.
+
+Every exchange of 'prompt' and 'chat' with a model is recorded in a SQLite
+database in the gemini-cli data directory (unless --no-log is passed to
+these commands). The data directory can be set with the GEMINI_CLI_DATA_DIR
+environment variable.
+`
+
+var logsListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List the latest log entries, newest first",
+	Args:    cobra.NoArgs,
+	Run:     runLogsListCmd,
+}
+
+var logsShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show a log entry in full",
+	Args:  cobra.ExactArgs(1),
+	Run:   runLogsShowCmd,
+}
+
+var logsSearchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search the log for prompts and responses",
+	Long: strings.TrimSpace(`
+Search the log for entries whose prompt or response match the query, which
+uses the SQLite full-text search syntax. For example:
+
+  gemini-cli logs search 'kubernetes AND ingress'
+  gemini-cli logs search '"exact phrase"'
+`),
+	Args: cobra.ExactArgs(1),
+	Run:  runLogsSearchCmd,
+}
+
+var logsAttachmentCmd = &cobra.Command{
+	Use:   "attachment <sha256> [output path]",
+	Short: "Write the data of an attachment of a log entry",
+	Long: strings.TrimSpace(`
+Write the data of an attachment (like an image) of a log entry to a file, or
+to stdout if no output path is given. Attachments are identified by the
+SHA-256 hash that 'logs show' prints for them, with or without its "sha256:"
+prefix.
+`),
+	Args: cobra.RangeArgs(1, 2),
+	Run:  runLogsAttachmentCmd,
+}
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+	logsCmd.AddCommand(logsListCmd)
+	logsCmd.AddCommand(logsShowCmd)
+	logsCmd.AddCommand(logsSearchCmd)
+	logsCmd.AddCommand(logsAttachmentCmd)
+
+	logsCmd.PersistentFlags().Bool("json", false, "print the entries as JSON")
+	logsListCmd.Flags().IntP("number", "n", 20, "number of entries to list; 0 lists all the entries")
+	logsSearchCmd.Flags().IntP("number", "n", 20, "maximal number of entries to list; 0 lists all the matches")
+}
+
+func openLog() *promptlog.DB {
+	path, err := promptlog.DefaultPath()
+	if err != nil {
+		log.Fatal(err)
+	}
+	db, err := promptlog.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return db
+}
+
+func runLogsListCmd(cmd *cobra.Command, args []string) {
+	db := openLog()
+	defer db.Close()
+
+	entries, err := db.List(mustGetIntFlag(cmd, "number"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	printLogEntries(cmd, entries)
+}
+
+func runLogsSearchCmd(cmd *cobra.Command, args []string) {
+	db := openLog()
+	defer db.Close()
+
+	entries, err := db.Search(args[0], mustGetIntFlag(cmd, "number"))
+	if err != nil {
+		log.Fatalf("searching log: %v", err)
+	}
+	printLogEntries(cmd, entries)
+}
+
+func runLogsShowCmd(cmd *cobra.Command, args []string) {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		log.Fatalf("expect numeric log entry ID, got %q", args[0])
+	}
+
+	db := openLog()
+	defer db.Close()
+
+	e, err := db.Get(id)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if mustGetBoolFlag(cmd, "json") {
+		if err := emitJSON(cmd.OutOrStdout(), e); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	printLogEntry(cmd.OutOrStdout(), e)
+}
+
+func runLogsAttachmentCmd(cmd *cobra.Command, args []string) {
+	db := openLog()
+	defer db.Close()
+
+	a, err := db.Attachment(strings.TrimPrefix(args[0], "sha256:"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(args) < 2 {
+		if _, err := cmd.OutOrStdout().Write(a.Data); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err := os.WriteFile(args[1], a.Data, 0644); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "wrote %d bytes of %s to %s\n", len(a.Data), a.MIMEType, args[1])
+}
+
+// printLogEntries prints a summary line for each entry, or all the entries
+// as a JSON array with --json.
+func printLogEntries(cmd *cobra.Command, entries []*promptlog.Entry) {
+	if mustGetBoolFlag(cmd, "json") {
+		if entries == nil {
+			entries = []*promptlog.Entry{}
+		}
+		if err := emitJSON(cmd.OutOrStdout(), entries); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	for _, e := range entries {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", e.ID, e.Time.Local().Format("2006-01-02 15:04"), e.Model, summarizeText(e.PromptText(), 60))
+	}
+	w.Flush()
+}
+
+// printLogEntry prints e in full to w.
+func printLogEntry(w io.Writer, e *promptlog.Entry) {
+	fmt.Fprintf(w, "id:            %d\n", e.ID)
+	fmt.Fprintf(w, "time:          %s\n", e.Time.Local().Format(time.DateTime))
+	fmt.Fprintf(w, "command:       %s\n", e.Command)
+	if e.Conversation != "" {
+		fmt.Fprintf(w, "conversation:  %s\n", e.Conversation)
+	}
+	fmt.Fprintf(w, "model:         %s\n", e.Model)
+	fmt.Fprintf(w, "duration:      %s\n", e.Duration.Round(time.Millisecond))
+	if e.Usage.CachedTokens > 0 {
+		fmt.Fprintf(w, "usage:         %d prompt (%d cached) + %d response = %d tokens\n",
+			e.Usage.PromptTokens, e.Usage.CachedTokens, e.Usage.ResponseTokens, e.Usage.TotalTokens)
+	} else {
+		fmt.Fprintf(w, "usage:         %d prompt + %d response = %d tokens\n",
+			e.Usage.PromptTokens, e.Usage.ResponseTokens, e.Usage.TotalTokens)
+	}
+	if e.FinishReason != "" {
+		fmt.Fprintf(w, "finish reason: %s\n", e.FinishReason)
+	}
+
+	if e.System != "" {
+		fmt.Fprintln(w, "\n--- system ---")
+		fmt.Fprintln(w, e.System)
+	}
+	for _, turn := range e.History {
+		fmt.Fprintf(w, "\n--- history: %s ---\n", turn.Role)
+		printLogParts(w, turn.Parts)
+	}
+	fmt.Fprintln(w, "\n--- prompt ---")
+	printLogParts(w, e.Parts)
+	fmt.Fprintln(w, "\n--- response ---")
+	fmt.Fprintln(w, e.Response)
+}
+
+// printLogParts prints the parts of a logged prompt to w.
+func printLogParts(w io.Writer, parts []promptlog.Part) {
+	for _, p := range parts {
+		switch p.Type {
+		case "text":
+			fmt.Fprintln(w, p.Text)
+		case "blob":
+			fmt.Fprintf(w, "[%s, %d bytes, sha256:%s]\n", p.MIMEType, p.Size, p.SHA256)
+		case "file":
+			fmt.Fprintf(w, "[%s, uploaded file %s]\n", p.MIMEType, p.URI)
+		default:
+			fmt.Fprintf(w, "[%s]\n", p.Type)
+		}
+	}
+}
+
+// summarizeText returns s on a single line, cut to at most n runes.
+func summarizeText(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-3]) + "..."
+	}
+	return s
+}
@@ -0,0 +1,256 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"slices"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// generativeModel is a Gemini model, with the configuration requests to it
+// are sent with: generation parameters, safety settings, system
+// instruction, tools and so on.
+type generativeModel struct {
+	genai.GenerateContentConfig
+
+	client *genai.Client
+	name   string
+}
+
+// newGenerativeModel returns the model named name, with the model's default
+// configuration.
+func newGenerativeModel(client *genai.Client, name string) *generativeModel {
+	return &generativeModel{client: client, name: name}
+}
+
+// generateContent sends parts to the model in a single user turn, and
+// returns its response.
+func (m *generativeModel) generateContent(ctx context.Context, parts ...*genai.Part) (*genai.GenerateContentResponse, error) {
+	return m.generate(ctx, []*genai.Content{genai.NewContentFromParts(parts, genai.RoleUser)})
+}
+
+// generateContentStream is like generateContent, but streams the response
+// in chunks.
+func (m *generativeModel) generateContentStream(ctx context.Context, parts ...*genai.Part) iter.Seq2[*genai.GenerateContentResponse, error] {
+	return m.generateStream(ctx, []*genai.Content{genai.NewContentFromParts(parts, genai.RoleUser)})
+}
+
+// generate sends contents to the model, and returns its response. Blocked
+// prompts and responses are reported with a *blockedError.
+func (m *generativeModel) generate(ctx context.Context, contents []*genai.Content) (*genai.GenerateContentResponse, error) {
+	resp, err := m.client.Models.GenerateContent(ctx, m.name, contents, &m.GenerateContentConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkBlocked(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// generateStream is like generate, but streams the response. A blocked
+// prompt or response ends the stream with a *blockedError.
+func (m *generativeModel) generateStream(ctx context.Context, contents []*genai.Content) iter.Seq2[*genai.GenerateContentResponse, error] {
+	return func(yield func(*genai.GenerateContentResponse, error) bool) {
+		for chunk, err := range m.client.Models.GenerateContentStream(ctx, m.name, contents, &m.GenerateContentConfig) {
+			if err == nil {
+				err = checkBlocked(chunk)
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(chunk, nil) {
+				return
+			}
+		}
+	}
+}
+
+// info returns information about the model, like its token limits.
+func (m *generativeModel) info(ctx context.Context) (*genai.Model, error) {
+	return m.client.Models.Get(ctx, m.name, nil)
+}
+
+// countTokens returns the number of tokens of contents for the model.
+func (m *generativeModel) countTokens(ctx context.Context, contents ...*genai.Content) (int32, error) {
+	resp, err := m.client.Models.CountTokens(ctx, m.name, contents, nil)
+	if err != nil {
+		return 0, err
+	}
+	return resp.TotalTokens, nil
+}
+
+// startChat starts a chat session with the model.
+func (m *generativeModel) startChat() *chatSession {
+	return &chatSession{model: m}
+}
+
+// chatSession is a chat with a model, which sends the history of the chat
+// with every message.
+type chatSession struct {
+	model *generativeModel
+
+	// History has the turns of the chat so far. A message that failed is
+	// left at its end, without a response.
+	History []*genai.Content
+}
+
+// sendMessage sends parts to the model in a user turn after the history of
+// the session, and returns the response. The turn, and the response of the
+// first candidate, are added to the history.
+func (cs *chatSession) sendMessage(ctx context.Context, parts ...*genai.Part) (*genai.GenerateContentResponse, error) {
+	cs.History = append(cs.History, genai.NewContentFromParts(parts, genai.RoleUser))
+	resp, err := cs.chatModel().generate(ctx, cs.History)
+	if err != nil {
+		return nil, err
+	}
+	cs.addResponse(resp)
+	return resp, nil
+}
+
+// sendMessageStream is like sendMessage, but streams the response and
+// presents it with out as it arrives (see streamResponse).
+func (cs *chatSession) sendMessageStream(ctx context.Context, out streamOutput, parts ...*genai.Part) (*genai.GenerateContentResponse, error) {
+	cs.History = append(cs.History, genai.NewContentFromParts(parts, genai.RoleUser))
+	resp, err := streamResponse(cs.chatModel().generateStream(ctx, cs.History), out)
+	if err != nil {
+		return nil, err
+	}
+	cs.addResponse(resp)
+	return resp, nil
+}
+
+// chatModel returns the model of the session, configured to generate a
+// single candidate: only one can continue the chat.
+func (cs *chatSession) chatModel() *generativeModel {
+	m := *cs.model
+	m.CandidateCount = 0
+	return &m
+}
+
+// addResponse adds the response of the first candidate of resp to the
+// history of the session.
+func (cs *chatSession) addResponse(resp *genai.GenerateContentResponse) {
+	if resp == nil || len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return
+	}
+	c := *resp.Candidates[0].Content
+	c.Role = genai.RoleModel
+	cs.History = append(cs.History, &c)
+}
+
+// blockedError is the error of a request whose prompt or response was
+// blocked, for example for safety reasons.
+type blockedError struct {
+	// PromptFeedback explains why the prompt was blocked, if it was.
+	PromptFeedback *genai.GenerateContentResponsePromptFeedback
+
+	// Candidate is the response candidate that was blocked, if any.
+	Candidate *genai.Candidate
+}
+
+func (e *blockedError) Error() string {
+	var b strings.Builder
+	b.WriteString("blocked: ")
+	if e.Candidate != nil {
+		fmt.Fprintf(&b, "candidate: %s", e.Candidate.FinishReason)
+	}
+	if e.PromptFeedback != nil {
+		if e.Candidate != nil {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "prompt: %s", e.PromptFeedback.BlockReason)
+	}
+	return b.String()
+}
+
+// checkBlocked returns a *blockedError if the prompt of resp was blocked, or
+// any of its candidates was blocked for safety or recitation, and nil
+// otherwise.
+func checkBlocked(resp *genai.GenerateContentResponse) error {
+	if pf := resp.PromptFeedback; pf != nil && pf.BlockReason != "" && pf.BlockReason != genai.BlockedReasonUnspecified {
+		return &blockedError{PromptFeedback: pf}
+	}
+	for _, c := range resp.Candidates {
+		if c.FinishReason == genai.FinishReasonSafety || c.FinishReason == genai.FinishReasonRecitation {
+			return &blockedError{Candidate: c}
+		}
+	}
+	return nil
+}
+
+// isTextPart reports whether part is a text part (which may be empty).
+func isTextPart(part *genai.Part) bool {
+	return part.InlineData == nil && part.FileData == nil && part.FunctionCall == nil &&
+		part.FunctionResponse == nil && part.ExecutableCode == nil && part.CodeExecutionResult == nil
+}
+
+// mergeChunk merges chunk, a chunk of a streamed response, into merged, the
+// response streamed so far (nil for the first chunk), and returns the
+// result. The parts of each candidate are appended, with adjacent pieces of
+// text joined; its other fields are taken from the latest chunk that sets
+// them.
+func mergeChunk(merged, chunk *genai.GenerateContentResponse) *genai.GenerateContentResponse {
+	if merged == nil {
+		merged = &genai.GenerateContentResponse{}
+	}
+	if chunk.PromptFeedback != nil {
+		merged.PromptFeedback = chunk.PromptFeedback
+	}
+	if chunk.UsageMetadata != nil {
+		merged.UsageMetadata = chunk.UsageMetadata
+	}
+	merged.ModelVersion = chunk.ModelVersion
+
+	for _, c := range chunk.Candidates {
+		i := slices.IndexFunc(merged.Candidates, func(mc *genai.Candidate) bool { return mc.Index == c.Index })
+		if i < 0 {
+			mc := *c
+			if c.Content != nil {
+				content := *c.Content
+				content.Parts = slices.Clone(c.Content.Parts)
+				mc.Content = &content
+			}
+			merged.Candidates = append(merged.Candidates, &mc)
+			continue
+		}
+
+		mc := merged.Candidates[i]
+		if c.Content != nil {
+			if mc.Content == nil {
+				mc.Content = &genai.Content{Role: c.Content.Role}
+			}
+			for _, p := range c.Content.Parts {
+				last := len(mc.Content.Parts) - 1
+				if last >= 0 && isTextPart(p) && isTextPart(mc.Content.Parts[last]) && p.Thought == mc.Content.Parts[last].Thought {
+					joined := *mc.Content.Parts[last]
+					joined.Text += p.Text
+					mc.Content.Parts[last] = &joined
+				} else {
+					mc.Content.Parts = append(mc.Content.Parts, p)
+				}
+			}
+		}
+		if c.FinishReason != "" {
+			mc.FinishReason = c.FinishReason
+		}
+		if len(c.SafetyRatings) > 0 {
+			mc.SafetyRatings = c.SafetyRatings
+		}
+		if c.CitationMetadata != nil {
+			var cited []*genai.Citation
+			if mc.CitationMetadata != nil {
+				cited = mc.CitationMetadata.Citations
+			}
+			mc.CitationMetadata = &genai.CitationMetadata{Citations: append(slices.Clip(cited), c.CitationMetadata.Citations...)}
+		}
+		if c.GroundingMetadata != nil {
+			mc.GroundingMetadata = c.GroundingMetadata
+		}
+	}
+	return merged
+}
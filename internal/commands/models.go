@@ -9,7 +9,6 @@ import (
 	"text/tabwriter"
 
 	"github.com/spf13/cobra"
-	"google.golang.org/api/iterator"
 )
 
 var modelsCmd = &cobra.Command{
@@ -46,19 +45,14 @@ func runModelsCmd(cmd *cobra.Command, args []string) {
 	ctx := context.Background()
 	client, err := newGenaiClient(ctx, cmd)
 	if err != nil {
-		log.Fatal()
+		log.Fatal(err)
 	}
 
 	w := tabwriter.NewWriter(os.Stdout, 6, 16, 1, '\t', 0)
 	fmt.Fprintf(w, "%-32s\tVersion\tMax In\tMax Out\tDescription\n", "Name")
 	fmt.Fprintf(w, "\n")
 
-	iter := client.ListModels(ctx)
-	for {
-		mi, err := iter.Next()
-		if err == iterator.Done {
-			break
-		}
+	for mi, err := range client.Models.All(ctx) {
 		if err != nil {
 			panic(err)
 		}
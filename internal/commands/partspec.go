@@ -0,0 +1,143 @@
+package commands
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// partKind is the kind of content a prompt argument refers to.
+type partKind int
+
+const (
+	textPart partKind = iota
+	filePart
+	urlPart
+	stdinPart
+)
+
+// partSpec is a prompt argument resolved to the kind of content it refers to;
+// value is the text, the file path or the URL.
+type partSpec struct {
+	kind  partKind
+	value string
+}
+
+// Prefixes that explicitly set the kind of a prompt argument.
+const (
+	textPrefix = "text:"
+	filePrefix = "file:"
+	urlPrefix  = "url:"
+	stdinSpec  = "stdin:"
+)
+
+// parsePartSpecs resolves prompt arguments to the content they refer to.
+//
+// Arguments with an explicit prefix mean what the prefix says: 'text:...' is
+// text, '@path' and 'file:path' are files (paths with glob patterns like
+// '@src/*.go' expand to all the matching files), 'url:...' is a URL and
+// 'stdin:' is the standard input.
+//
+// Other arguments are resolved by heuristics, unless literal is true (in
+// which case they're text): '-' is the standard input, http and https URLs
+// are URLs, and single words with an extension (like 'image.png') are files
+// if they exist. Everything else is text.
+func parsePartSpecs(args []string, literal bool) ([]partSpec, error) {
+	var specs []partSpec
+	seenStdin := false
+	for _, arg := range args {
+		var spec partSpec
+		switch {
+		case strings.HasPrefix(arg, textPrefix):
+			spec = partSpec{textPart, strings.TrimPrefix(arg, textPrefix)}
+		case strings.HasPrefix(arg, "@"):
+			spec = partSpec{filePart, arg[1:]}
+		case strings.HasPrefix(arg, filePrefix):
+			spec = partSpec{filePart, strings.TrimPrefix(arg, filePrefix)}
+		case strings.HasPrefix(arg, urlPrefix):
+			spec = partSpec{urlPart, strings.TrimPrefix(arg, urlPrefix)}
+		case arg == stdinSpec:
+			spec = partSpec{kind: stdinPart}
+		case literal:
+			spec = partSpec{textPart, arg}
+		case arg == "-":
+			spec = partSpec{kind: stdinPart}
+		case argLooksLikeURL(arg):
+			spec = partSpec{urlPart, arg}
+		case argLooksLikeFilename(arg):
+			spec = partSpec{filePart, arg}
+		default:
+			spec = partSpec{textPart, arg}
+		}
+
+		switch spec.kind {
+		case stdinPart:
+			if seenStdin {
+				return nil, fmt.Errorf("expect standard input ('-' or '%s') at most once in list of prompts", stdinSpec)
+			}
+			seenStdin = true
+		case filePart:
+			if spec.value == "" {
+				return nil, fmt.Errorf("expect a path after %q", arg)
+			}
+			paths, err := expandPathPattern(spec.value)
+			if err != nil {
+				return nil, err
+			}
+			for _, path := range paths {
+				specs = append(specs, partSpec{filePart, path})
+			}
+			continue
+		case urlPart:
+			if !argLooksLikeURL(spec.value) {
+				return nil, fmt.Errorf("expect an http or https URL, got %q", arg)
+			}
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// expandPathPattern returns the paths matching pattern, in lexical order. A
+// path without glob metacharacters is returned as is, whether or not it
+// exists.
+func expandPathPattern(pattern string) ([]string, error) {
+	if !strings.ContainsAny(pattern, `*?[`) {
+		return []string{pattern}, nil
+	}
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("bad pattern %q: %w", pattern, err)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no files match %q", pattern)
+	}
+	return paths, nil
+}
+
+var filenameRe = regexp.MustCompile(`^\S+\.[a-zA-Z][a-zA-Z0-9]*$`)
+
+// argLooksLikeFilename says if command-line argument looks like a filename,
+// which we consider to be a single word with an extension (starting with a
+// letter, like .txt or .mp3) following a dot separator, but not look like a
+// URL, that names an existing file. Text like "what is in main.go", and words
+// like "e.g" or "node.js" that aren't files, aren't considered filenames.
+func argLooksLikeFilename(arg string) bool {
+	if !filenameRe.MatchString(arg) || strings.Contains(arg, "://") {
+		return false
+	}
+	_, err := os.Stat(arg)
+	return err == nil
+}
+
+// argLooksLikeURL says if command-line argument is an http or https URL.
+func argLooksLikeURL(arg string) bool {
+	u, err := url.ParseRequestURI(arg)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
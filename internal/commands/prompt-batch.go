@@ -0,0 +1,462 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/eliben/gemini-cli/internal/jsonschema"
+	"github.com/eliben/gemini-cli/internal/pricing"
+	"github.com/eliben/gemini-cli/internal/tableloader"
+	"github.com/eliben/gemini-cli/internal/templates"
+	"github.com/spf13/cobra"
+	"golang.org/x/time/rate"
+	"google.golang.org/genai"
+)
+
+var promptBatchCmd = &cobra.Command{
+	Use:   "batch <input file or '-'>",
+	Short: "Send a prompt for every row of a table, storing the responses",
+	Long:  strings.TrimSpace(promptBatchUsage),
+	Args:  cobra.ExactArgs(1),
+	Run:   runPromptBatchCmd,
+}
+
+var promptBatchUsage = `
+Send a prompt to the model for every row of an input table, and store the
+responses. This is the generation counterpart of 'embed db'.
+
+The input is read from a file provided as an argument (or '-', which reads
+from standard input). The format of the file should be either CSV, TSV
+(tab-separated), JSON or JSONLines (one line per JSON object). Every row
+must have an ID column ('id' by default, see --id-column).
+
+The prompt for each row is rendered from a Go text/template, with the row's
+columns as variables; for example --prompt 'Summarize: {{.content}}'.
+Alternatively, --template names a stored template (see the 'templates'
+command), which can also set the system prompt, model, temperature and
+schema.
+
+Responses are stored either in a table of a SQLite DB (--db) or in a JSONL
+file (--jsonl), keyed by the row's ID. Rows that already have a response
+there are skipped, so an interrupted or partially failed run can be resumed
+by running the same command again. Rows that fail (including responses that
+were blocked or don't match the schema) are reported and not stored.
+`
+
+func init() {
+	promptCmd.AddCommand(promptBatchCmd)
+
+	promptBatchCmd.Flags().String("prompt", "", "prompt template rendered for each row, with the row's columns as variables")
+	promptBatchCmd.Flags().String("template", "", "name of a stored prompt template to render for each row")
+	promptBatchCmd.Flags().String("schema", "", "path of a JSON Schema file; the model responds with JSON matching this schema")
+	promptBatchCmd.Flags().String("id-column", "id", "name of the input column holding the row ID")
+
+	promptBatchCmd.Flags().String("db", "", "path of a SQLite DB to store the responses into")
+	promptBatchCmd.Flags().String("table", "responses", "DB table name to store the responses into")
+	promptBatchCmd.Flags().String("jsonl", "", "path of a JSONL file to store the responses into")
+
+	promptBatchCmd.Flags().Int("concurrency", 4, "maximal number of requests in flight")
+	promptBatchCmd.Flags().Float64("rpm", 0, "maximal number of requests per minute; 0 means no limit")
+
+	addSystemFlags(promptBatchCmd)
+	addUsageFlags(promptBatchCmd)
+	addGenerationFlags(promptBatchCmd)
+}
+
+// batchItem is a single row of a batch, with its rendered prompt.
+type batchItem struct {
+	id     string
+	system string
+	prompt string
+}
+
+// batchResult is the stored response for a single row of a batch.
+type batchResult struct {
+	ID           string `json:"id"`
+	Prompt       string `json:"prompt"`
+	Response     string `json:"response"`
+	FinishReason string `json:"finish_reason"`
+	Model        string `json:"model"`
+
+	usage pricing.Usage
+}
+
+// batchStore stores the results of a batch. Its methods are safe for
+// concurrent use.
+type batchStore interface {
+	// doneIDs returns the IDs of rows that already have a stored result.
+	doneIDs() (map[string]bool, error)
+
+	store(r *batchResult) error
+	Close() error
+}
+
+func runPromptBatchCmd(cmd *cobra.Command, args []string) {
+	promptText := mustGetStringFlag(cmd, "prompt")
+	templateName := mustGetStringFlag(cmd, "template")
+	var tmpl *templates.Template
+	switch {
+	case promptText != "" && templateName != "":
+		log.Fatal("--prompt and --template are mutually exclusive")
+	case promptText != "":
+		tmpl = &templates.Template{Prompt: promptText}
+	case templateName != "":
+		var err error
+		tmpl, err = templates.Load(templateName)
+		if err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatal("expect a prompt template with --prompt or --template")
+	}
+
+	dbPath := mustGetStringFlag(cmd, "db")
+	jsonlPath := mustGetStringFlag(cmd, "jsonl")
+	if (dbPath == "") == (jsonlPath == "") {
+		log.Fatal("expect exactly one of --db or --jsonl")
+	}
+
+	concurrency := mustGetIntFlag(cmd, "concurrency")
+	if concurrency < 1 {
+		log.Fatalf("expect --concurrency to be at least 1, got %d", concurrency)
+	}
+	rpm, _ := cmd.Flags().GetFloat64("rpm")
+	if rpm < 0 {
+		log.Fatalf("expect --rpm to be non-negative, got %v", rpm)
+	}
+
+	items := loadBatchItems(cmd, args[0], tmpl)
+	usage := newUsageMeter(cmd)
+
+	var store batchStore
+	var err error
+	if dbPath != "" {
+		store, err = newDBBatchStore(dbPath, mustGetStringFlag(cmd, "table"))
+	} else {
+		store, err = newJSONLBatchStore(jsonlPath)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer store.Close()
+
+	done, err := store.doneIDs()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var todo []batchItem
+	for _, item := range items {
+		if !done[item.id] {
+			todo = append(todo, item)
+		}
+	}
+	log.Printf("Found %d rows; %d already have responses, %d to send", len(items), len(items)-len(todo), len(todo))
+	if len(todo) == 0 {
+		return
+	}
+
+	ctx := context.Background()
+	client, err := newGenaiClient(ctx, cmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	modelName, model, schema := newPromptModel(cmd, client, tmpl)
+	if model.CandidateCount > 1 {
+		log.Fatal("batch mode supports a single candidate")
+	}
+
+	limiter := rate.NewLimiter(rate.Inf, 1)
+	if rpm > 0 {
+		limiter = rate.NewLimiter(rate.Limit(rpm/60), 1)
+	}
+
+	var mu sync.Mutex
+	var numFailed int
+	itemsCh := make(chan batchItem)
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range itemsCh {
+				if err := limiter.Wait(ctx); err != nil {
+					log.Fatal(err)
+				}
+
+				// The system instruction may differ between rows, so each row
+				// gets its own copy of the model.
+				itemModel := *model
+				applySystemInstruction(&itemModel, item.system)
+				parts := []*genai.Part{genai.NewPartFromText(item.prompt)}
+
+				result, err := runBatchItem(ctx, &itemModel, parts, schema)
+				if result != nil {
+					usage.add(modelName, result.usage)
+				}
+				if err == nil {
+					result.ID = item.id
+					result.Prompt = item.prompt
+					result.Model = modelName
+					err = store.store(result)
+				}
+				if err != nil {
+					log.Printf("row %s: %v", item.id, err)
+					mu.Lock()
+					numFailed++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, item := range todo {
+		itemsCh <- item
+	}
+	close(itemsCh)
+	wg.Wait()
+
+	log.Printf("Stored %d responses; %d rows failed", len(todo)-numFailed, numFailed)
+	usage.reportTotals()
+	if numFailed > 0 {
+		store.Close()
+		os.Exit(1)
+	}
+}
+
+// loadBatchItems loads the input table from the file at path (or standard
+// input, for '-'), and renders the prompt for every row with tmpl. The system
+// prompt is rendered from tmpl too, unless it's set with --system or
+// --system-file.
+func loadBatchItems(cmd *cobra.Command, path string, tmpl *templates.Template) []batchItem {
+	var inputReader io.Reader
+	if path == "-" {
+		inputReader = cmd.InOrStdin()
+	} else {
+		file, err := os.Open(path)
+		if err != nil {
+			log.Fatalf("unable to open %v: %v", path, err)
+		}
+		defer file.Close()
+		inputReader = file
+	}
+
+	_, table, err := tableloader.LoadTable(inputReader, tableloader.FormatUnknown)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	idColumn := mustGetStringFlag(cmd, "id-column")
+	systemFlag := systemInstruction(cmd, "")
+	seen := make(map[string]bool)
+	var items []batchItem
+	for _, row := range table {
+		id, ok := row[idColumn]
+		if !ok {
+			log.Fatalf("expect input row to have '%s' column; got %v", idColumn, row)
+		}
+		if seen[id] {
+			log.Fatalf("duplicate row ID %q in input", id)
+		}
+		seen[id] = true
+
+		system, prompt, err := tmpl.Render(row)
+		if err != nil {
+			log.Fatalf("rendering prompt for row %s: %v", id, err)
+		}
+		if systemFlag != "" {
+			system = systemFlag
+		}
+		items = append(items, batchItem{id: id, system: system, prompt: prompt})
+	}
+	return items
+}
+
+// runBatchItem sends parts to model and returns the result. Blocked
+// responses and responses not matching schema (if not nil) are errors; for
+// the latter, a result with only the usage of the request is returned along
+// with the error.
+func runBatchItem(ctx context.Context, model *generativeModel, parts []*genai.Part, schema *jsonschema.Schema) (*batchResult, error) {
+	resp, err := model.generateContent(ctx, parts...)
+	if err != nil {
+		var blocked *blockedError
+		if errors.As(err, &blocked) {
+			var sb strings.Builder
+			reportBlocked(&sb, blocked)
+			return nil, errors.New(strings.TrimSpace(sb.String()))
+		}
+		return nil, err
+	}
+	if len(resp.Candidates) == 0 {
+		return nil, errors.New("empty response from model")
+	}
+	if schema != nil {
+		if err := checkResponseSchema(schema, resp); err != nil {
+			return &batchResult{usage: responseUsage(resp)}, fmt.Errorf("response doesn't match schema: %w", err)
+		}
+	}
+
+	c := resp.Candidates[0]
+	return &batchResult{
+		Response:     candidateText(c),
+		FinishReason: apiEnumName(c.FinishReason, "FINISH_REASON_"),
+		usage:        responseUsage(resp),
+	}, nil
+}
+
+// dbBatchStore stores batch results in a table of a SQLite DB.
+type dbBatchStore struct {
+	mu    sync.Mutex
+	db    *sql.DB
+	table string
+}
+
+func newDBBatchStore(path string, table string) (*dbBatchStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open DB at '%v': %w", path, err)
+	}
+
+	_, err = db.Exec(fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %s (
+id TEXT PRIMARY KEY,
+prompt TEXT,
+response TEXT,
+finish_reason TEXT,
+model TEXT
+)`, table))
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to create table '%v' in DB: %w", table, err)
+	}
+	return &dbBatchStore{db: db, table: table}, nil
+}
+
+func (s *dbBatchStore) doneIDs() (map[string]bool, error) {
+	rows, err := s.db.Query(fmt.Sprintf("SELECT id FROM %s", s.table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	done := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		done[id] = true
+	}
+	return done, rows.Err()
+}
+
+func (s *dbBatchStore) store(r *batchResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(fmt.Sprintf("INSERT OR REPLACE INTO %s VALUES (?, ?, ?, ?, ?)", s.table),
+		r.ID, r.Prompt, r.Response, r.FinishReason, r.Model)
+	return err
+}
+
+func (s *dbBatchStore) Close() error {
+	return s.db.Close()
+}
+
+// jsonlBatchStore stores batch results in a JSONL file, one result per line.
+type jsonlBatchStore struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+}
+
+func newJSONLBatchStore(path string) (*jsonlBatchStore, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonlBatchStore{path: path, f: f}, nil
+}
+
+// doneIDs reads the IDs of the results in the file. A run that was
+// interrupted while writing a result leaves a partial last line; it's removed
+// from the file, so that new results are appended after the complete ones,
+// and its row is sent again. Other lines that aren't valid results are
+// errors.
+func (s *jsonlBatchStore) doneIDs() (map[string]bool, error) {
+	f, err := os.Open(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	done := make(map[string]bool)
+	r := bufio.NewReader(f)
+	var offset int64
+	var line []byte
+
+	// badLine is the offset of the last line read if it isn't a valid result,
+	// or -1; badErr is the error it reported.
+	badLine := int64(-1)
+	var badErr error
+	for lineno := 1; ; lineno++ {
+		line, err = r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			if badLine >= 0 {
+				return nil, badErr
+			}
+			var res batchResult
+			if err := json.Unmarshal(line, &res); err != nil {
+				badLine = offset
+				badErr = fmt.Errorf("%s:%d: %w", s.path, lineno, err)
+			} else {
+				done[res.ID] = true
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		offset += int64(len(line))
+	}
+
+	if badLine >= 0 {
+		log.Printf("Dropping the incomplete result on the last line of %s", s.path)
+		return done, os.Truncate(s.path, badLine)
+	}
+	if len(line) > 0 {
+		// A complete result without a newline still needs one before new
+		// results are appended.
+		_, err := s.f.Write([]byte("\n"))
+		return done, err
+	}
+	return done, nil
+}
+
+func (s *jsonlBatchStore) store(r *batchResult) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.f.Write(append(b, '\n'))
+	return err
+}
+
+func (s *jsonlBatchStore) Close() error {
+	return s.f.Close()
+}
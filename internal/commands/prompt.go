@@ -1,27 +1,27 @@
 package commands
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
-	"net/http"
-	"net/url"
 	"os"
-	"path/filepath"
-	"regexp"
-	"strconv"
 	"strings"
+	"time"
 
-	"github.com/google/generative-ai-go/genai"
+	"github.com/eliben/gemini-cli/internal/jsonschema"
+	"github.com/eliben/gemini-cli/internal/mediatype"
+	"github.com/eliben/gemini-cli/internal/templates"
 	"github.com/spf13/cobra"
-	"google.golang.org/api/iterator"
+	"google.golang.org/genai"
 )
 
 var promptCmd = &cobra.Command{
 	Use:     "prompt <prompt or '-'>...",
 	Aliases: []string{"p", "ask"},
-	Args:    cobra.MinimumNArgs(1),
+	Args:    promptArgs,
 	Short:   "Send a prompt to a Gemini model",
 	Long:    strings.TrimSpace(promptUsage),
 	Run:     runPromptCmd,
@@ -32,197 +32,403 @@ Send a prompt to the LLM. The prompt can be provided as a sequence of parts,
 each one a command-line argument.
 
 The arguments are sent as a sequence to the model in the order provided.
-If --system is provided, it's prepended to the other arguments. An argument
-can be some quoted text, a name of an image file on the local filesystem or
-a URL pointing directly to an image file online. A special argument with
-the value '-' instructs the tool to read this prompt part from standard input.
-It can only appear once in a single invocation.
+A system instruction for the model can be set with --system, or read from
+a file with --system-file. An argument can be some quoted text, a name of a
+file on the local filesystem (text, an image, a PDF, audio or video) or a
+URL. Web pages are sent as their readable text; text, images, PDFs and other
+media fetched from URLs are sent like the corresponding files. A special
+argument with the value '-' instructs the tool to read this prompt part from
+standard input. It can only appear once in a single invocation.
+
+Arguments are interpreted as follows; a prefix makes the meaning of an
+argument explicit:
+
+  text:<text>    the text after the prefix
+  @<path>        a file; a glob pattern (like @src/*.go) adds all the
+  file:<path>    matching files, in order
+  url:<url>      the content of an http or https URL
+  stdin:         the standard input (like '-')
+
+Arguments without a prefix are guessed: http and https URLs are URLs, and
+single words with an extension (like image.png) are files if they exist;
+anything else is text. With --literal, arguments without a prefix are always
+text.
+
+A first argument of 'batch' runs the 'prompt batch' command, so a prompt of
+that single word has to be given as text:batch.
+
+With --context, the text files in directories or matching glob patterns
+(where '**' matches any number of directories) are sent before the other
+arguments, each wrapped in a <file path="..."> element. Files ignored by
+.gitignore, binary files and files over the size limits are skipped; use
+--context-manifest to see which files were included.
 
 If you're providing multi-modal prompts (e.g. with images), make sure to
 select an appropriate model like gemini-pro-vision
 (see https://ai.google.dev/models/gemini for a list of model names).
+
+With --extract, only the fenced code blocks in the response are printed (of
+the first candidate, if there are several), so they can be piped to other
+tools; --extract-lang only extracts blocks in the given language. With
+--extract-to, each block is written to a file in the given directory, and
+the paths of the files are printed. A file path in the info string after the
+opening fence (like "go main.go", "go:main.go" or "go title=main.go") names
+the block's file if it's inside the directory; other blocks are written to
+files named block-<n>.<ext>.
+
+With --models, the prompt is sent to several models (a comma-separated list
+of names) concurrently, and their responses are shown together, with the
+latency and token usage of each: one after another with headers, or next to
+each other with --layout=columns. With --output json, the responses are
+printed as a JSON array of response objects, one per model; a model that
+failed has an "error" field.
+
+With --conversation, the turns of a YAML file (a list of user and model
+turns, each with a text or a list of text, file and URL parts) are sent as
+the history before the prompt, which is useful for few-shot prompting. If the
+last turn is a user turn, the arguments are sent in the same turn, and can be
+omitted.
+
+With --template, the prompt is rendered from a named template (see the
+'templates' command) with variables set by --var, and sent before the other
+arguments. The template's system prompt, model, temperature and schema are
+used unless overridden by the corresponding flags.
+
+With --cached-content, content cached with the 'cache-content' command is
+sent before the prompt, with the model it was cached for. The number of
+prompt tokens taken from the cache is reported.
 `
 
+// promptArgs checks the arguments of 'prompt': at least one is required,
+// unless the prompt comes from a template or a conversation file.
+func promptArgs(cmd *cobra.Command, args []string) error {
+	if mustGetStringFlag(cmd, "template") != "" || mustGetStringFlag(cmd, "conversation") != "" {
+		return nil
+	}
+	return cobra.MinimumNArgs(1)(cmd, args)
+}
+
 func init() {
 	rootCmd.AddCommand(promptCmd)
 
-	promptCmd.Flags().StringP("system", "s", "", "set a system prompt")
 	promptCmd.Flags().Bool("stream", true, "stream the response from the model")
+	promptCmd.Flags().String("stream-format", "text", `format of streamed responses: "text" or "jsonl" (a JSON event per line, for programs presenting responses as they arrive)`)
+	promptCmd.Flags().Int32("candidates", 1, "number of response candidates to generate; more than 1 disables streaming")
+	promptCmd.Flags().StringP("output", "o", "text", `output format: "text" or "json" (a JSON object with the response and its metadata)`)
+	promptCmd.Flags().String("schema", "", "path of a JSON Schema file; the model responds with JSON matching this schema")
+	promptCmd.Flags().String("template", "", "name of a prompt template to use; see the 'templates' command")
+	promptCmd.Flags().Bool("literal", false, "send arguments without a prefix (like @ or url:) as text, without guessing whether they're files or URLs")
+	promptCmd.Flags().StringArray("var", nil, "set a template variable as key=value; can be repeated")
 
-	// The temperature setting is a string because we want to set it only if
-	// the user provided it explicitly, keeping the model's default otherwise.
-	promptCmd.Flags().String("temp", "", "temperature setting for the model")
+	addSystemFlags(promptCmd)
+	addGenerationFlags(promptCmd)
+	addToolsFlags(promptCmd)
+	addCodeExecFlags(promptCmd)
+	addGroundingFlags(promptCmd)
+	addLogFlags(promptCmd)
+	addUploadFlags(promptCmd)
+	addURLFlags(promptCmd)
+	addContextFlags(promptCmd)
+	addTokenBudgetFlags(promptCmd)
+	addRenderFlags(promptCmd)
+	addExtractFlags(promptCmd)
+	addCompareFlags(promptCmd)
+	addConversationFlags(promptCmd)
+	addCachedContentFlags(promptCmd)
+	addUsageFlags(promptCmd)
+	promptCmd.Flags().Bool("cache", false, "answer from the local response cache if an identical request was cached; cache the response otherwise")
 }
 
 func runPromptCmd(cmd *cobra.Command, args []string) {
+	tmpl := loadPromptTemplate(cmd)
+	budget := newTokenBudget(cmd)
+
+	outputFormat := mustGetStringFlag(cmd, "output")
+	if outputFormat != "text" && outputFormat != "json" {
+		log.Fatalf("expect --output to be text or json, got %q", outputFormat)
+	}
+	streamFormat := mustGetStringFlag(cmd, "stream-format")
+	if streamFormat != "text" && streamFormat != "jsonl" {
+		log.Fatalf("expect --stream-format to be text or jsonl, got %q", streamFormat)
+	}
+	if streamFormat == "jsonl" && outputFormat == "json" {
+		log.Fatal("--stream-format jsonl can't be used with --output json")
+	}
+	// Extracted code is printed as is, once the whole response has arrived.
+	extractor := newCodeExtractor(cmd)
+	if extractor != nil && (outputFormat != "text" || streamFormat != "text") {
+		log.Fatal("--extract can only be used with text output")
+	}
+	render := outputFormat == "text" && streamFormat == "text" && extractor == nil && shouldRender(cmd)
+	compareModels := compareModelNames(cmd)
+	usage := newUsageMeter(cmd)
+	// Cached responses only keep the text of the response, without the code
+	// the model ran or the sources it was grounded in.
+	if mustGetBoolFlag(cmd, "cache") && mustGetBoolFlag(cmd, "code-exec") {
+		log.Fatal("--cache can't be used with --code-exec")
+	}
+	if mustGetBoolFlag(cmd, "cache") && mustGetBoolFlag(cmd, "grounding") {
+		log.Fatal("--cache can't be used with --grounding")
+	}
+	// Requests with a history or tools are sent in a chat session (also when
+	// comparing models), which only generates a single candidate.
+	if candidates, _ := cmd.Flags().GetInt32("candidates"); candidates > 1 {
+		if mustGetStringFlag(cmd, "conversation") != "" {
+			log.Fatal("--candidates can't be used with --conversation")
+		}
+		if mustGetStringFlag(cmd, "tools") != "" {
+			log.Fatal("--candidates can't be used with --tools")
+		}
+	}
+
+	// Files above a size threshold are uploaded with the File API.
+	ctx := context.Background()
+	uploader := newFileUploader(cmd, nil)
+	fetcher, err := newURLFetcher(cmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The turns of a conversation file are sent as history, except for a
+	// last user turn, which is sent with the prompt.
+	history, err := conversationHistory(ctx, cmd, uploader, fetcher)
+	if err != nil {
+		log.Fatal(err)
+	}
+	history, lastTurn := splitLastUserTurn(history)
+	if history != nil && lastTurn == nil && len(args) == 0 && tmpl == nil {
+		log.Fatal("expect a prompt after a conversation ending with a model turn")
+	}
+
 	// Build up parts of prompt.
-	var promptParts []genai.Part
+	var promptParts []*genai.Part
 
-	if sysPrompt := mustGetStringFlag(cmd, "system"); sysPrompt != "" {
-		promptParts = append(promptParts, genai.Text(sysPrompt))
+	var tmplSystem string
+	if tmpl != nil {
+		tmplSystem = tmpl.system
 	}
+	system := systemInstruction(cmd, tmplSystem)
+	cachedContent := cachedContentFlag(cmd, system)
 
-	seenStdin := false
-	for _, arg := range args {
-		if arg == "-" {
-			if seenStdin {
-				log.Fatal("expect a single '-' in list of prompts")
-			}
+	promptParts = append(promptParts, lastTurn...)
+	if part := contextPart(cmd); part != nil {
+		promptParts = append(promptParts, part)
+	}
+	if tmpl != nil && tmpl.prompt != "" {
+		promptParts = append(promptParts, genai.NewPartFromText(tmpl.prompt))
+	}
 
+	specs, err := parsePartSpecs(args, mustGetBoolFlag(cmd, "literal"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, spec := range specs {
+		switch spec.kind {
+		case stdinPart:
 			b, err := io.ReadAll(cmd.InOrStdin())
 			if err != nil {
 				log.Fatal("error reading content from stdin:", err)
 			}
-			promptParts = append(promptParts, genai.Text(string(b)))
-			seenStdin = true
-		} else if argLooksLikeURL(arg) {
-			part, err := getPartFromURL(arg)
+			promptParts = append(promptParts, genai.NewPartFromText(string(b)))
+		case urlPart:
+			part, err := fetcher.part(ctx, spec.value)
 			if err != nil {
 				log.Fatal(err)
 			}
 			promptParts = append(promptParts, part)
-		} else if argLooksLikeFilename(arg) {
-			part, err := getPartFromFile(arg)
+		case filePart:
+			part, err := uploader.partFromFile(ctx, spec.value)
 			if err != nil {
 				log.Fatal(err)
 			}
 			promptParts = append(promptParts, part)
-		} else {
-			promptParts = append(promptParts, genai.Text(arg))
+		default:
+			promptParts = append(promptParts, genai.NewPartFromText(spec.value))
 		}
 	}
 
-	ctx := context.Background()
 	client, err := newGenaiClient(ctx, cmd)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer client.Close()
-
-	model := client.GenerativeModel(mustGetStringFlag(cmd, "model"))
-
-	if tempValue := mustGetStringFlag(cmd, "temp"); tempValue != "" {
-		f, err := strconv.ParseFloat(tempValue, 32)
-		if err != nil {
-			log.Fatalf("problem parsing --temp value: %v", err)
-		}
-		model.SetTemperature(float32(f))
-	}
-
-	model.SafetySettings = []*genai.SafetySetting{
-		{
-			Category:  genai.HarmCategoryDangerousContent,
-			Threshold: genai.HarmBlockNone,
-		},
-		{
-			Category:  genai.HarmCategoryHarassment,
-			Threshold: genai.HarmBlockNone,
-		},
-		{
-			Category:  genai.HarmCategoryHateSpeech,
-			Threshold: genai.HarmBlockNone,
-		},
-		{
-			Category:  genai.HarmCategorySexuallyExplicit,
-			Threshold: genai.HarmBlockNone,
-		},
-	}
-
-	if stream := mustGetBoolFlag(cmd, "stream"); stream {
-		iter := model.GenerateContentStream(ctx, promptParts...)
-		for {
-			resp, err := iter.Next()
-			if err == iterator.Done {
-				break
-			}
-			if err != nil {
-				log.Fatal(err)
-			}
-			if len(resp.Candidates) < 1 {
-				fmt.Println("<empty response from model>")
-			} else {
-				c := resp.Candidates[0]
-				if c.Content != nil {
-					for _, part := range c.Content.Parts {
-						fmt.Print(part)
-					}
-				} else {
-					fmt.Println("<empty response from model>")
-				}
-			}
+
+	var t *templates.Template
+	if tmpl != nil {
+		t = tmpl.Template
+	}
+	if compareModels != nil {
+		runCompare(ctx, cmd, client, t, compareModels, system, history, promptParts, budget, outputFormat, usage)
+		return
+	}
+	var modelName string
+	var model *generativeModel
+	var schema *jsonschema.Schema
+	if cachedContent != "" {
+		modelName, model, schema = newCachedContentPromptModel(ctx, cmd, client, t, cachedContent)
+	} else {
+		modelName, model, schema = newPromptModel(cmd, client, t)
+	}
+	applySystemInstruction(model, system)
+
+	// Streaming responses are printed chunk by chunk as they arrive, which only
+	// makes sense for a single candidate in text output. JSONL events carry
+	// the candidate they belong to, so they're always streamed.
+	var out streamOutput
+	var events *jsonlStreamOutput
+	if streamFormat == "jsonl" {
+		events = newJSONLStreamOutput(os.Stdout)
+		out = events
+	} else if mustGetBoolFlag(cmd, "stream") && outputFormat == "text" && extractor == nil {
+		if model.CandidateCount <= 1 {
+			out = newTextStreamOutput(render)
+		}
+	}
+
+	// With tools, the model may need several rounds of function calls and
+	// responses, so the prompt is sent in a chat session.
+	tr := newToolRunner(cmd, bufio.NewReader(cmd.InOrStdin()))
+	model.Tools = modelTools(cmd, modelName, tr)
+
+	// With --cache, the response cached for an identical earlier request is
+	// used instead of sending the request again.
+	var rc *responseCache
+	var resp *genai.GenerateContentResponse
+	if mustGetBoolFlag(cmd, "cache") {
+		if tr != nil {
+			log.Fatal("--cache can't be used with --tools")
+		}
+		if rc = newResponseCache(modelName, model, history, promptParts); rc != nil {
+			defer rc.Close()
+			resp = rc.get()
+		}
+	}
+	cached := resp != nil
+
+	start := time.Now()
+	if cached {
+		// The cached response is printed in full below, or as a single chunk
+		// of events.
+		if events != nil {
+			err = events.chunk(resp)
+		} else {
+			out = nil
 		}
-		fmt.Println()
 	} else {
-		resp, err := model.GenerateContent(ctx, promptParts...)
-		if err != nil {
+		// Requests over the token budget are refused (or truncated) before
+		// sending them.
+		if promptParts, err = budget.check(ctx, model, history, promptParts); err != nil {
 			log.Fatal(err)
 		}
-		if len(resp.Candidates) < 1 {
-			fmt.Println("<empty response from model>")
+		start = time.Now()
+		if tr != nil || history != nil {
+			session := model.startChat()
+			session.History = history
+			resp, err = sendMessage(ctx, session, promptParts, out, tr)
 		} else {
-			c := resp.Candidates[0]
-			if c.Content != nil {
-				for _, part := range c.Content.Parts {
-					fmt.Println(part)
-				}
+			resp, err = generateResponse(ctx, model, promptParts, out)
+		}
+	}
+	latency := time.Since(start)
+	if err != nil {
+		if events != nil {
+			events.fail(err)
+		}
+		var blocked *blockedError
+		if outputFormat == "json" && errors.As(err, &blocked) {
+			emitJSON(os.Stdout, newBlockedResponseEnvelope(modelName, model, blocked, latency))
+		}
+		fatalGenerateError(err)
+	}
+
+	if !cached {
+		logger := newExchangeLogger(cmd, false)
+		logger.log(modelName, model.SystemInstruction, history, promptParts, resp, latency)
+		logger.Close()
+
+		if rc != nil {
+			rc.put(resp)
+		}
+	}
+
+	switch {
+	case events != nil:
+		if err := events.done(modelName, resp, latency, cached); err != nil {
+			log.Fatal(err)
+		}
+	case extractor != nil:
+		reportFinishReasons(os.Stderr, resp)
+		if cachedContent != "" {
+			reportCachedTokens(os.Stderr, resp)
+		}
+		if err := extractor.extract(os.Stdout, resp); err != nil {
+			log.Fatal(err)
+		}
+	case outputFormat == "text":
+		if out == nil {
+			if render {
+				printRenderedCandidates(os.Stdout, resp)
 			} else {
-				fmt.Println("<empty response from model>")
+				printCandidates(os.Stdout, resp)
 			}
 		}
+		reportFinishReasons(os.Stderr, resp)
+		if cachedContent != "" {
+			reportCachedTokens(os.Stderr, resp)
+		}
+	case outputFormat == "json":
+		env := newResponseEnvelope(modelName, model, resp, latency)
+		env.Cached = cached
+		if err := emitJSON(os.Stdout, env); err != nil {
+			log.Fatal(err)
+		}
 	}
-}
 
-// argLooksLikeFilename says if command-line argument looks like a filename,
-// which we consider to have an alphabetical extension following a dot separator,
-// but not look like a URL.
-func argLooksLikeFilename(arg string) bool {
-	re := regexp.MustCompile(`\.[a-zA-Z]+$`)
-	return re.MatchString(arg) && strings.Index(arg, "://") < 0
-}
+	// Responses from the local cache didn't use any tokens.
+	if !cached {
+		usage.report(modelName, responseUsage(resp))
+	}
 
-func argLooksLikeURL(arg string) bool {
-	_, err := url.ParseRequestURI(arg)
-	if err != nil {
-		return false
+	if schema != nil {
+		if err := checkResponseSchema(schema, resp); err != nil {
+			log.Fatalf("response doesn't match schema: %v", err)
+		}
 	}
-	return true
 }
 
-func getPartFromFile(path string) (genai.Part, error) {
-	b, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
+// generateResponse sends parts to model and returns its response. If out is
+// not nil, the response is streamed and presented with out as it arrives.
+func generateResponse(ctx context.Context, model *generativeModel, parts []*genai.Part, out streamOutput) (*genai.GenerateContentResponse, error) {
+	if out == nil {
+		return model.generateContent(ctx, parts...)
 	}
 
-	ext := filepath.Ext(path)
-	switch strings.TrimSpace(ext) {
-	case ".jpg", ".jpeg":
-		return genai.ImageData("jpeg", b), nil
-	case ".png":
-		return genai.ImageData("png", b), nil
-	default:
-		// Otherwise treat file as text
-		return genai.Text(string(b)), err
-	}
+	return streamResponse(model.generateContentStream(ctx, parts...), out)
 }
 
-func getPartFromURL(url string) (genai.Part, error) {
-	resp, err := http.Get(url)
+// getPartFromFile reads the file at path into a prompt part: text files
+// become text parts, and files of media types models accept (like images,
+// PDFs, audio and video) become blobs.
+func getPartFromFile(path string) (*genai.Part, error) {
+	data, mediaType, err := readMediaFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch image from url: %w", err)
+		return nil, err
+	}
+	if mediatype.IsText(mediaType) {
+		return genai.NewPartFromText(mediatype.DecodeText(data)), nil
 	}
-	defer resp.Body.Close()
+	return genai.NewPartFromBytes(data, mediaType), nil
+}
 
-	urlData, err := io.ReadAll(resp.Body)
+// readMediaFile reads the file at path, and detects its media type. Binary
+// files of types models don't accept are rejected.
+func readMediaFile(path string) ([]byte, string, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read image bytes: %w", err)
+		return nil, "", err
 	}
-
-	mimeType := resp.Header.Get("Content-Type")
-	parts := strings.Split(mimeType, "/")
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid mime type %v", mimeType)
+	mediaType := mediatype.Detect(path, data)
+	if !mediatype.IsText(mediaType) && !mediatype.IsSupported(mediaType) {
+		return nil, "", fmt.Errorf("%s: unsupported file type (%s)", path, mediaType)
 	}
-
-	return genai.ImageData(parts[1], urlData), nil
+	return data, mediaType, nil
 }
@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/eliben/gemini-cli/internal/contextpack"
+	"github.com/spf13/cobra"
+	"google.golang.org/genai"
+)
+
+// Default size limits (in KiB) for files packed with --context.
+const (
+	defaultContextMaxFileSize = 256
+	defaultContextMaxSize     = 2048
+)
+
+// addContextFlags adds the flags for packing files into the prompt to cmd.
+func addContextFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray("context", nil, "directory or glob pattern (like 'pkg/**/*.go') of text files to send as context; can be repeated")
+	cmd.Flags().Int("context-max-file-size", defaultContextMaxFileSize, "skip --context files larger than this many KiB")
+	cmd.Flags().Int("context-max-size", defaultContextMaxSize, "maximal total size (in KiB) of the --context files; files beyond it are skipped")
+	cmd.Flags().Bool("context-manifest", false, "print the list of files included by --context (and the files skipped) to stderr")
+}
+
+// contextPart packs the files selected with --context into a single text
+// part. It returns nil if --context wasn't used.
+func contextPart(cmd *cobra.Command) *genai.Part {
+	specs := mustGetStringArrayFlag(cmd, "context")
+	if len(specs) == 0 {
+		return nil
+	}
+
+	pack, err := contextpack.New(specs, contextpack.Options{
+		MaxFileSize:  int64(mustGetIntFlag(cmd, "context-max-file-size")) << 10,
+		MaxTotalSize: int64(mustGetIntFlag(cmd, "context-max-size")) << 10,
+	})
+	if err != nil {
+		log.Fatalf("--context: %v", err)
+	}
+	if len(pack.Files) == 0 {
+		log.Fatal("--context: no text files to include")
+	}
+
+	if mustGetBoolFlag(cmd, "context-manifest") {
+		printContextManifest(pack)
+	} else {
+		tooLarge := 0
+		for _, s := range pack.Skipped {
+			if s.Reason != contextpack.ReasonBinary {
+				tooLarge++
+			}
+		}
+		if tooLarge > 0 {
+			fmt.Fprintf(os.Stderr, "warning: --context: skipped %d files over the size limits (see --context-manifest)\n", tooLarge)
+		}
+	}
+	return genai.NewPartFromText(pack.Text)
+}
+
+func printContextManifest(pack *contextpack.Pack) {
+	fmt.Fprintf(os.Stderr, "context: %d files, %s\n", len(pack.Files), formatSize(pack.Size()))
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	for _, f := range pack.Files {
+		fmt.Fprintf(w, "  %s\t%s\n", f.Path, formatSize(f.Size))
+	}
+	w.Flush()
+	if len(pack.Skipped) > 0 {
+		fmt.Fprintf(os.Stderr, "skipped: %d files\n", len(pack.Skipped))
+		for _, s := range pack.Skipped {
+			fmt.Fprintf(w, "  %s\t%s\n", s.Path, s.Reason)
+		}
+		w.Flush()
+	}
+}
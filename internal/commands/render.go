@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/eliben/gemini-cli/internal/mdrender"
+	"github.com/spf13/cobra"
+	"google.golang.org/genai"
+)
+
+// addRenderFlags adds the flag controlling Markdown rendering of responses
+// to cmd.
+func addRenderFlags(cmd *cobra.Command) {
+	cmd.Flags().String("render", "auto", "render Markdown in responses for the terminal: auto (when stdout is a terminal), always or never")
+}
+
+// shouldRender reports whether responses printed to stdout should have
+// their Markdown rendered, according to the --render flag of cmd. With
+// "auto", Markdown is rendered when stdout is a terminal, unless the NO_COLOR
+// environment variable is set.
+func shouldRender(cmd *cobra.Command) bool {
+	switch render := mustGetStringFlag(cmd, "render"); render {
+	case "auto":
+		return isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == ""
+	case "always":
+		return true
+	case "never":
+		return false
+	default:
+		log.Fatalf("expect --render to be auto, always or never, got %q", render)
+		return false
+	}
+}
+
+// newTextStreamOutput creates a streamOutput printing text to stdout,
+// rendering its Markdown if render is true.
+func newTextStreamOutput(render bool) *textStreamOutput {
+	if render {
+		md := mdrender.NewStream(os.Stdout, terminalWidth(os.Stdout))
+		return &textStreamOutput{w: md, md: md}
+	}
+	return &textStreamOutput{w: os.Stdout}
+}
+
+// printRenderedCandidates is like printCandidates, but renders the Markdown
+// of the candidates' text.
+func printRenderedCandidates(w io.Writer, resp *genai.GenerateContentResponse) {
+	var sb strings.Builder
+	printCandidates(&sb, resp)
+	fmt.Fprint(w, mdrender.Render(sb.String()))
+}
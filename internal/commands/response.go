@@ -0,0 +1,186 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// printCandidates prints the contents of all the candidates in resp to w.
+// When there's more than one candidate, each one is preceded by a separator
+// line naming it.
+func printCandidates(w io.Writer, resp *genai.GenerateContentResponse) {
+	if len(resp.Candidates) < 1 {
+		fmt.Fprintln(w, "<empty response from model>")
+		return
+	}
+
+	for i, c := range resp.Candidates {
+		if len(resp.Candidates) > 1 {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "--- candidate %d/%d ---\n", i+1, len(resp.Candidates))
+		}
+
+		if c.Content != nil {
+			for i, part := range c.Content.Parts {
+				text := formatPart(part)
+				if isTextPart(part) {
+					text = groundedText(text, i, c.GroundingMetadata)
+				}
+				fmt.Fprintln(w, text)
+			}
+		} else {
+			fmt.Fprintln(w, "<empty response from model>")
+		}
+		printCitations(w, citationSources(c))
+		printGroundingSources(w, c.GroundingMetadata)
+	}
+}
+
+// candidateText returns the concatenation of all the text parts of c.
+func candidateText(c *genai.Candidate) string {
+	if c.Content == nil {
+		return ""
+	}
+	var sb strings.Builder
+	for _, part := range c.Content.Parts {
+		if isTextPart(part) {
+			sb.WriteString(part.Text)
+		}
+	}
+	return sb.String()
+}
+
+// citationSources returns the distinct sources cited by c.
+func citationSources(c *genai.Candidate) []*genai.Citation {
+	if c.CitationMetadata == nil {
+		return nil
+	}
+	return uniqueCitations(c.CitationMetadata.Citations)
+}
+
+// uniqueCitations returns sources without repeated citations of the same
+// source (a response may cite several passages of it).
+func uniqueCitations(cited []*genai.Citation) []*genai.Citation {
+	var sources []*genai.Citation
+	seen := make(map[string]bool)
+	for _, src := range cited {
+		key := src.License
+		if src.URI != "" {
+			key = src.URI
+		}
+		if !seen[key] {
+			seen[key] = true
+			sources = append(sources, src)
+		}
+	}
+	return sources
+}
+
+// printCitations prints the sources cited by a candidate to w, numbered.
+func printCitations(w io.Writer, sources []*genai.Citation) {
+	if len(sources) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nCitations:")
+	for i, src := range sources {
+		uri := "(no URL)"
+		if src.URI != "" {
+			uri = src.URI
+		}
+		if src.License != "" {
+			fmt.Fprintf(w, "[%d] %s (license: %s)\n", i+1, uri, src.License)
+		} else {
+			fmt.Fprintf(w, "[%d] %s\n", i+1, uri)
+		}
+	}
+}
+
+// reportFinishReasons writes to w an explanation for every candidate in resp
+// that didn't finish normally (e.g. it was cut short by the token limit).
+// Nothing is written for candidates that finished normally.
+func reportFinishReasons(w io.Writer, resp *genai.GenerateContentResponse) {
+	if resp == nil {
+		return
+	}
+	for _, c := range resp.Candidates {
+		if c.FinishReason == genai.FinishReasonStop || c.FinishReason == genai.FinishReasonUnspecified || c.FinishReason == "" {
+			continue
+		}
+		if len(resp.Candidates) > 1 {
+			fmt.Fprintf(w, "candidate %d: ", c.Index+1)
+		}
+		reportCandidateFinish(w, c)
+	}
+}
+
+// reportCandidateFinish writes the finish reason and safety ratings of c to w.
+func reportCandidateFinish(w io.Writer, c *genai.Candidate) {
+	fmt.Fprintf(w, "response finished with reason %s\n", finishReasonName(c.FinishReason))
+	reportSafetyRatings(w, c.SafetyRatings)
+}
+
+// reportBlocked writes an explanation for a blocked prompt or response to w.
+func reportBlocked(w io.Writer, blocked *blockedError) {
+	if pf := blocked.PromptFeedback; pf != nil {
+		fmt.Fprintf(w, "prompt blocked with reason %s\n", trimEnumName(pf.BlockReason, "BLOCKED_REASON_"))
+		reportSafetyRatings(w, pf.SafetyRatings)
+	}
+	if c := blocked.Candidate; c != nil {
+		fmt.Fprint(w, "response blocked: ")
+		reportCandidateFinish(w, c)
+	}
+}
+
+// reportSafetyRatings writes safety ratings to w, one per line.
+func reportSafetyRatings(w io.Writer, ratings []*genai.SafetyRating) {
+	if len(ratings) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "safety ratings:")
+	for _, r := range ratings {
+		blocked := ""
+		if r.Blocked {
+			blocked = " (blocked)"
+		}
+		fmt.Fprintf(w, "  %-20s %s%s\n",
+			trimEnumName(r.Category, "HARM_CATEGORY_"),
+			trimEnumName(r.Probability, "HARM_PROBABILITY_"),
+			blocked)
+	}
+}
+
+// fatalGenerateError reports err - returned from a content generation call -
+// and exits. Blocked responses are reported in detail.
+func fatalGenerateError(err error) {
+	var blocked *blockedError
+	if errors.As(err, &blocked) {
+		reportBlocked(os.Stderr, blocked)
+		os.Exit(1)
+	}
+	log.Fatal(err)
+}
+
+func finishReasonName(fr genai.FinishReason) string {
+	return trimEnumName(fr, "FINISH_REASON_")
+}
+
+// trimEnumName returns the name of a genai enum value for people: without
+// the prefix of its type's values, and in camel case (e.g.
+// "HARM_CATEGORY_DANGEROUS_CONTENT" becomes "DangerousContent").
+func trimEnumName[E ~string](v E, prefix string) string {
+	words := strings.Split(strings.ToLower(apiEnumName(v, prefix)), "_")
+	for i, w := range words {
+		if w != "" {
+			words[i] = strings.ToUpper(w[:1]) + w[1:]
+		}
+	}
+	return strings.Join(words, "")
+}
@@ -2,6 +2,7 @@ package commands
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/eliben/gemini-cli/internal/version"
 	"github.com/spf13/cobra"
@@ -33,6 +34,8 @@ func init() {
 	rootCmd.PersistentFlags().String("key", "", "API key for Google AI")
 	rootCmd.PersistentFlags().String("model", "gemini-1.5-flash", "Name of model to use; see https://ai.google.dev/models/gemini")
 	rootCmd.PersistentFlags().String("proxy", "", "URL of proxy server to use for the connection")
+	rootCmd.PersistentFlags().Int("retries", 3, "number of times to retry API requests failing with transient errors (HTTP 429 or 5xx)")
+	rootCmd.PersistentFlags().Duration("retry-max-wait", time.Minute, "maximal time to wait before retrying an API request")
 
 	rootCmd.Flags().BoolP("version", "v", false, `print version info and exit`)
 }
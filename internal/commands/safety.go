@@ -0,0 +1,123 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/eliben/gemini-cli/internal/config"
+	"github.com/spf13/cobra"
+	"google.golang.org/genai"
+)
+
+// safetyCategories are the harm categories that can be configured with
+// --safety. The keys are the names accepted by the flag; the special name
+// "all" refers to all of them.
+var safetyCategories = map[string]genai.HarmCategory{
+	"harassment": genai.HarmCategoryHarassment,
+	"hate":       genai.HarmCategoryHateSpeech,
+	"sexual":     genai.HarmCategorySexuallyExplicit,
+	"dangerous":  genai.HarmCategoryDangerousContent,
+}
+
+// safetyThresholds are the block thresholds that can be configured with
+// --safety; see https://ai.google.dev/gemini-api/docs/safety-settings
+var safetyThresholds = map[string]genai.HarmBlockThreshold{
+	"none":   genai.HarmBlockThresholdBlockNone,
+	"high":   genai.HarmBlockThresholdBlockOnlyHigh,
+	"medium": genai.HarmBlockThresholdBlockMediumAndAbove,
+	"low":    genai.HarmBlockThresholdBlockLowAndAbove,
+}
+
+// addSafetyFlags adds the --safety flag to cmd.
+func addSafetyFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray("safety", nil, strings.TrimSpace(`
+safety setting as category=threshold; can be repeated.
+Categories: harassment, hate, sexual, dangerous or all.
+Thresholds: none, high, medium or low (blocks content
+with this probability of harm and above)`))
+}
+
+// applySafetyFlags sets the safety settings of model. By default, nothing
+// is blocked; this can be changed by the 'safety' section of the config file,
+// which can in turn be overridden by --safety flags.
+func applySafetyFlags(cmd *cobra.Command, model *generativeModel) {
+	thresholds := make(map[genai.HarmCategory]genai.HarmBlockThreshold)
+	for _, c := range safetyCategories {
+		thresholds[c] = genai.HarmBlockThresholdBlockNone
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Sort the config entries so that "all" is applied first, and specific
+	// categories can override it.
+	for _, name := range slices.SortedFunc(maps.Keys(cfg.Safety), compareSafetyCategoryNames) {
+		if err := setSafetyThreshold(thresholds, name, cfg.Safety[name]); err != nil {
+			log.Fatalf("problem in 'safety' section of config: %v", err)
+		}
+	}
+
+	flagSettings, _ := cmd.Flags().GetStringArray("safety")
+	for _, setting := range flagSettings {
+		name, value, found := strings.Cut(setting, "=")
+		if !found {
+			log.Fatalf("expect category=threshold for --safety, got %q", setting)
+		}
+		if err := setSafetyThreshold(thresholds, name, value); err != nil {
+			log.Fatalf("problem parsing --safety value: %v", err)
+		}
+	}
+
+	model.SafetySettings = nil
+	for _, c := range slices.Sorted(maps.Keys(thresholds)) {
+		model.SafetySettings = append(model.SafetySettings, &genai.SafetySetting{
+			Category:  c,
+			Threshold: thresholds[c],
+		})
+	}
+}
+
+// setSafetyThreshold sets the threshold named value for the category named
+// name in thresholds.
+func setSafetyThreshold(thresholds map[genai.HarmCategory]genai.HarmBlockThreshold, name, value string) error {
+	name = strings.ToLower(strings.TrimSpace(name))
+	value = strings.ToLower(strings.TrimSpace(value))
+
+	threshold, ok := safetyThresholds[value]
+	if !ok {
+		return fmt.Errorf("unknown safety threshold %q", value)
+	}
+
+	if name == "all" {
+		for c := range thresholds {
+			thresholds[c] = threshold
+		}
+		return nil
+	}
+
+	category, ok := safetyCategories[name]
+	if !ok {
+		return fmt.Errorf("unknown safety category %q", name)
+	}
+	thresholds[category] = threshold
+	return nil
+}
+
+// compareSafetyCategoryNames orders category names alphabetically, but with
+// "all" before anything else.
+func compareSafetyCategoryNames(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "all":
+		return -1
+	case b == "all":
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/eliben/gemini-cli/internal/jsonschema"
+	"google.golang.org/genai"
+)
+
+// loadSchemaFile loads a JSON Schema from the file at path.
+func loadSchemaFile(path string) (*jsonschema.Schema, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := jsonschema.Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("schema %v: %w", path, err)
+	}
+	return schema, nil
+}
+
+// applyResponseSchema configures model to respond with JSON that matches
+// schema.
+func applyResponseSchema(model *generativeModel, schema *jsonschema.Schema) {
+	model.ResponseMIMEType = "application/json"
+	model.ResponseSchema = toGenaiSchema(schema)
+}
+
+// toGenaiSchema converts a parsed JSON Schema to a genai.Schema. Keywords
+// that genai.Schema has no place for (like minimum) are dropped; they're
+// still checked locally when the response is validated.
+func toGenaiSchema(s *jsonschema.Schema) *genai.Schema {
+	if s == nil {
+		return nil
+	}
+
+	gs := &genai.Schema{
+		Description: s.Description,
+		Format:      s.Format,
+		Items:       toGenaiSchema(s.Items),
+		Required:    s.Required,
+	}
+	if s.Nullable {
+		gs.Nullable = genai.Ptr(true)
+	}
+
+	switch s.Type {
+	case jsonschema.TypeObject:
+		gs.Type = genai.TypeObject
+	case jsonschema.TypeArray:
+		gs.Type = genai.TypeArray
+	case jsonschema.TypeString:
+		gs.Type = genai.TypeString
+	case jsonschema.TypeNumber:
+		gs.Type = genai.TypeNumber
+	case jsonschema.TypeInteger:
+		gs.Type = genai.TypeInteger
+	case jsonschema.TypeBoolean:
+		gs.Type = genai.TypeBoolean
+	}
+
+	// The model only supports enums of strings, with the "enum" format.
+	if s.Type == jsonschema.TypeString && len(s.Enum) > 0 {
+		gs.Format = "enum"
+		for _, e := range s.Enum {
+			gs.Enum = append(gs.Enum, fmt.Sprint(e))
+		}
+	}
+
+	if len(s.Properties) > 0 {
+		gs.Properties = make(map[string]*genai.Schema)
+		for name, ps := range s.Properties {
+			gs.Properties[name] = toGenaiSchema(ps)
+		}
+	}
+	return gs
+}
+
+// checkResponseSchema validates the text of every candidate in resp against
+// schema, returning an error describing the first mismatch.
+func checkResponseSchema(schema *jsonschema.Schema, resp *genai.GenerateContentResponse) error {
+	if resp == nil || len(resp.Candidates) == 0 {
+		return fmt.Errorf("no response to validate")
+	}
+	for _, c := range resp.Candidates {
+		if err := schema.ValidateJSON([]byte(candidateText(c))); err != nil {
+			if len(resp.Candidates) > 1 {
+				return fmt.Errorf("candidate %d: %w", c.Index+1, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
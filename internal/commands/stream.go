@@ -0,0 +1,270 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/eliben/gemini-cli/internal/mdrender"
+	"google.golang.org/genai"
+)
+
+// streamOutput presents the chunks of streamed responses as they arrive.
+type streamOutput interface {
+	// chunk presents a single response chunk. An error stops the stream.
+	chunk(chunk *genai.GenerateContentResponse) error
+
+	// end is called when a streamed response is complete. With function
+	// calls, a single request may stream several responses.
+	end()
+}
+
+// streamResponse presents the response chunks from chunks with out as they
+// arrive, and returns the merged response (see mergeChunk).
+func streamResponse(chunks iter.Seq2[*genai.GenerateContentResponse, error], out streamOutput) (*genai.GenerateContentResponse, error) {
+	var resp *genai.GenerateContentResponse
+	for chunk, err := range chunks {
+		if err != nil {
+			return nil, err
+		}
+		if err := out.chunk(chunk); err != nil {
+			return nil, err
+		}
+
+		// Every chunk reports the usage so far, so the merged response has
+		// the total.
+		resp = mergeChunk(resp, chunk)
+	}
+	out.end()
+	return resp, nil
+}
+
+// textStreamOutput prints the text of response chunks to w. If md is set,
+// w is md, which renders the Markdown of the text.
+type textStreamOutput struct {
+	w  io.Writer
+	md *mdrender.Stream
+
+	// midLine is set when the text printed so far doesn't end with a newline.
+	midLine bool
+
+	// citations are the sources cited by the response, and grounding the
+	// search results it was grounded in; they're printed at its end.
+	citations []*genai.Citation
+	grounding *genai.GroundingMetadata
+}
+
+func (o *textStreamOutput) chunk(chunk *genai.GenerateContentResponse) error {
+	if len(chunk.Candidates) < 1 {
+		fmt.Fprintln(o.w, "<empty response from model>")
+		return nil
+	}
+	c := chunk.Candidates[0]
+	if c.Content != nil {
+		for _, part := range c.Content.Parts {
+			// Function calls are reported by the tool runner.
+			if part.FunctionCall != nil {
+				continue
+			}
+			text := formatPart(part)
+			if !isTextPart(part) {
+				// Code run by the model is printed in blocks of its own lines.
+				if o.midLine {
+					fmt.Fprintln(o.w)
+				}
+				text += "\n"
+			}
+			fmt.Fprint(o.w, text)
+			if text != "" {
+				o.midLine = !strings.HasSuffix(text, "\n")
+			}
+		}
+	}
+	if c.CitationMetadata != nil {
+		o.citations = append(o.citations, c.CitationMetadata.Citations...)
+	}
+	if c.GroundingMetadata != nil {
+		o.grounding = c.GroundingMetadata
+	}
+	return nil
+}
+
+func (o *textStreamOutput) end() {
+	if o.midLine {
+		fmt.Fprintln(o.w)
+		o.midLine = false
+	}
+	printCitations(o.w, uniqueCitations(o.citations))
+	printGroundingSources(o.w, o.grounding)
+	o.citations = nil
+	o.grounding = nil
+	if o.md != nil {
+		o.md.Flush()
+	}
+}
+
+// jsonlStreamOutput writes response chunks to w as a stream of JSON events,
+// one per line (NDJSON), for programs that present responses as they
+// arrive. Every event has a "type":
+//
+//   - "text": a piece of the text of a candidate.
+//   - "function_call": a function call by the model.
+//   - "executable_code", "code_execution_result": code run by the model with
+//     the code execution tool, and its result.
+//   - "citation": a source cited by a candidate.
+//   - "grounding": the search results a candidate was grounded in (see
+//     groundingEnvelope), whenever they change.
+//   - "safety": the safety ratings of a candidate, whenever they change.
+//   - "done": the end of the response, with the finish reasons of the
+//     candidates and the token usage.
+//   - "error": the request failed.
+type jsonlStreamOutput struct {
+	enc *json.Encoder
+
+	// err is the first error writing an event; once it's set, no more events
+	// are written.
+	err error
+
+	// safety has the safety ratings last reported for each candidate, and
+	// grounding its grounding.
+	safety    map[int32][]safetyRatingEnvelope
+	grounding map[int32]*groundingEnvelope
+}
+
+func newJSONLStreamOutput(w io.Writer) *jsonlStreamOutput {
+	return &jsonlStreamOutput{
+		enc:       json.NewEncoder(w),
+		safety:    make(map[int32][]safetyRatingEnvelope),
+		grounding: make(map[int32]*groundingEnvelope),
+	}
+}
+
+type textEvent struct {
+	Type      string `json:"type"`
+	Candidate int32  `json:"candidate"`
+	Text      string `json:"text"`
+}
+
+type functionCallEvent struct {
+	Type      string         `json:"type"`
+	Candidate int32          `json:"candidate"`
+	Name      string         `json:"name"`
+	Args      map[string]any `json:"args"`
+}
+
+// codeExecutionEvent is a codeExecutionEnvelope with the candidate it
+// belongs to.
+type codeExecutionEvent struct {
+	codeExecutionEnvelope
+	Candidate int32 `json:"candidate"`
+}
+
+type citationEvent struct {
+	Type      string `json:"type"`
+	Candidate int32  `json:"candidate"`
+	citationEnvelope
+}
+
+type groundingEvent struct {
+	Type      string `json:"type"`
+	Candidate int32  `json:"candidate"`
+	*groundingEnvelope
+}
+
+type safetyEvent struct {
+	Type          string                 `json:"type"`
+	Candidate     int32                  `json:"candidate"`
+	SafetyRatings []safetyRatingEnvelope `json:"safety_ratings"`
+}
+
+type doneEvent struct {
+	Type           string                  `json:"type"`
+	Model          string                  `json:"model"`
+	FinishReasons  []string                `json:"finish_reasons"`
+	PromptFeedback *promptFeedbackEnvelope `json:"prompt_feedback,omitempty"`
+	Usage          *usageEnvelope          `json:"usage,omitempty"`
+	LatencyMs      int64                   `json:"latency_ms"`
+	Cached         bool                    `json:"cached,omitempty"`
+}
+
+type errorEvent struct {
+	Type        string `json:"type"`
+	Error       string `json:"error"`
+	BlockReason string `json:"block_reason,omitempty"`
+}
+
+func (o *jsonlStreamOutput) chunk(chunk *genai.GenerateContentResponse) error {
+	for _, c := range chunk.Candidates {
+		if c.Content != nil {
+			for _, part := range c.Content.Parts {
+				switch {
+				case part.FunctionCall != nil:
+					o.encode(functionCallEvent{Type: "function_call", Candidate: c.Index, Name: part.FunctionCall.Name, Args: part.FunctionCall.Args})
+				case part.ExecutableCode != nil || part.CodeExecutionResult != nil:
+					o.encode(codeExecutionEvent{newCodeExecutionEnvelope(part), c.Index})
+				case isTextPart(part):
+					o.encode(textEvent{Type: "text", Candidate: c.Index, Text: part.Text})
+				}
+			}
+		}
+		if cm := c.CitationMetadata; cm != nil {
+			for _, src := range cm.Citations {
+				o.encode(citationEvent{Type: "citation", Candidate: c.Index, citationEnvelope: newCitationEnvelope(src)})
+			}
+		}
+		if ge := newGroundingEnvelope(c.GroundingMetadata); ge != nil && !reflect.DeepEqual(ge, o.grounding[c.Index]) {
+			o.grounding[c.Index] = ge
+			o.encode(groundingEvent{Type: "grounding", Candidate: c.Index, groundingEnvelope: ge})
+		}
+		if ratings := newSafetyRatingEnvelopes(c.SafetyRatings); len(ratings) > 0 && !reflect.DeepEqual(ratings, o.safety[c.Index]) {
+			o.safety[c.Index] = ratings
+			o.encode(safetyEvent{Type: "safety", Candidate: c.Index, SafetyRatings: ratings})
+		}
+	}
+	return o.err
+}
+
+func (o *jsonlStreamOutput) end() {}
+
+// encode writes the event ev, unless writing an earlier event failed. It
+// returns the first error writing an event.
+func (o *jsonlStreamOutput) encode(ev any) error {
+	if o.err == nil {
+		o.err = o.enc.Encode(ev)
+	}
+	return o.err
+}
+
+// done writes the final event of the response resp, generated by the model
+// named modelName in the given latency.
+func (o *jsonlStreamOutput) done(modelName string, resp *genai.GenerateContentResponse, latency time.Duration, cached bool) error {
+	env := newResponseEnvelope(modelName, &generativeModel{}, resp, latency)
+	ev := doneEvent{
+		Type:           "done",
+		Model:          modelName,
+		FinishReasons:  []string{},
+		PromptFeedback: env.PromptFeedback,
+		Usage:          env.Usage,
+		LatencyMs:      env.LatencyMs,
+		Cached:         cached,
+	}
+	for _, c := range env.Candidates {
+		ev.FinishReasons = append(ev.FinishReasons, c.FinishReason)
+	}
+	return o.encode(ev)
+}
+
+// fail writes an error event for err.
+func (o *jsonlStreamOutput) fail(err error) {
+	ev := errorEvent{Type: "error", Error: err.Error()}
+	var blocked *blockedError
+	if errors.As(err, &blocked) && blocked.PromptFeedback != nil {
+		ev.BlockReason = apiEnumName(blocked.PromptFeedback.BlockReason, "BLOCKED_REASON_")
+	}
+	o.encode(ev)
+}
@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/genai"
+)
+
+// addSystemFlags adds the flags setting the system instruction to cmd.
+func addSystemFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("system", "s", "", "set a system prompt (sent to the model as its system instruction)")
+	cmd.Flags().String("system-file", "", "path of a file with the system instruction, for long instructions")
+}
+
+// systemInstruction returns the system instruction set with the --system or
+// --system-file flags of cmd. If neither is set, it returns def.
+func systemInstruction(cmd *cobra.Command, def string) string {
+	path := mustGetStringFlag(cmd, "system-file")
+	if path == "" {
+		if cmd.Flags().Changed("system") {
+			return mustGetStringFlag(cmd, "system")
+		}
+		return def
+	}
+	if cmd.Flags().Changed("system") {
+		log.Fatal("--system and --system-file can't be used together")
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return string(b)
+}
+
+// applySystemInstruction sets the system instruction of model to text, unless
+// it's empty.
+func applySystemInstruction(model *generativeModel, text string) {
+	if strings.TrimSpace(text) != "" {
+		model.SystemInstruction = genai.NewContentFromText(text, genai.RoleUser)
+	}
+}
+
+// systemText returns the text of the system instruction si, or "" if si is
+// nil.
+func systemText(si *genai.Content) string {
+	if si == nil {
+		return ""
+	}
+	var texts []string
+	for _, part := range si.Parts {
+		if isTextPart(part) {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
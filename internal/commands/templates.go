@@ -0,0 +1,250 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/eliben/gemini-cli/internal/jsonschema"
+	"github.com/eliben/gemini-cli/internal/templates"
+	"github.com/spf13/cobra"
+	"google.golang.org/genai"
+)
+
+var templatesCmd = &cobra.Command{
+	Use:   "templates",
+	Short: "Manage named prompt templates",
+	Long:  strings.TrimSpace(templatesUsage),
+
+	// 'templates' is a parent of subcommands, and doesn't do anything on its
+	// own. Therefore we don't define a Run: function for it.
+}
+
+var templatesUsage = `
+Manage named prompt templates, for use with 'prompt --template'.
+
+Templates are stored as YAML files in the templates/ directory of the
+configuration directory. A template has a prompt and optionally a system
+prompt, model name, temperature and response schema; for example:
+
+  description: Summarize text in a given language
+  model: gemini-1.5-pro
+  temperature: 0.2
+  system: You answer in {{.lang}}.
+  prompt: Summarize the following text in {{.sentences}} sentences.
+  schema:
+    type: object
+    properties:
+      summary: {type: string}
+
+The prompt and system prompt are Go text/template templates; variables are
+set with 'prompt --var key=value'.
+`
+
+var templatesListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List the stored templates",
+	Args:    cobra.NoArgs,
+	Run:     runTemplatesListCmd,
+}
+
+var templatesShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Show a template",
+	Args:  cobra.ExactArgs(1),
+	Run:   runTemplatesShowCmd,
+}
+
+var templatesEditCmd = &cobra.Command{
+	Use:   "edit <name>",
+	Short: "Edit a template with $EDITOR, creating it if it doesn't exist",
+	Args:  cobra.ExactArgs(1),
+	Run:   runTemplatesEditCmd,
+}
+
+var templatesDeleteCmd = &cobra.Command{
+	Use:     "delete <name>",
+	Aliases: []string{"rm"},
+	Short:   "Delete a template",
+	Args:    cobra.ExactArgs(1),
+	Run:     runTemplatesDeleteCmd,
+}
+
+// newTemplateSkeleton is the initial content of a template created with
+// 'templates edit'.
+var newTemplateSkeleton = `# description: what this template is for
+# system: a system prompt
+# model: gemini-1.5-flash
+# temperature: 0.5
+prompt: |
+  Your prompt here; refer to variables like {{.name}}
+`
+
+func init() {
+	rootCmd.AddCommand(templatesCmd)
+	templatesCmd.AddCommand(templatesListCmd)
+	templatesCmd.AddCommand(templatesShowCmd)
+	templatesCmd.AddCommand(templatesEditCmd)
+	templatesCmd.AddCommand(templatesDeleteCmd)
+}
+
+func runTemplatesListCmd(cmd *cobra.Command, args []string) {
+	names, err := templates.List()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	for _, name := range names {
+		var description string
+		if t, err := templates.Load(name); err != nil {
+			description = fmt.Sprintf("<error: %v>", err)
+		} else {
+			description = t.Description
+		}
+		fmt.Fprintf(w, "%s\t%s\n", name, description)
+	}
+	w.Flush()
+}
+
+func runTemplatesShowCmd(cmd *cobra.Command, args []string) {
+	path, err := templates.Path(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		log.Fatalf("%v: %s", templates.ErrNotFound, args[0])
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	cmd.OutOrStdout().Write(b)
+}
+
+func runTemplatesEditCmd(cmd *cobra.Command, args []string) {
+	path, err := templates.Path(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(newTemplateSkeleton), 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+	// $EDITOR may include arguments, like "code --wait".
+	editorArgs := strings.Fields(editor)
+	ed := exec.Command(editorArgs[0], append(editorArgs[1:], path)...)
+	ed.Stdin = os.Stdin
+	ed.Stdout = os.Stdout
+	ed.Stderr = os.Stderr
+	if err := ed.Run(); err != nil {
+		log.Fatalf("running editor: %v", err)
+	}
+
+	if _, err := templates.Load(args[0]); err != nil {
+		log.Fatalf("%v\n(the template was saved; run 'templates edit %s' again to fix it)", err, args[0])
+	}
+}
+
+func runTemplatesDeleteCmd(cmd *cobra.Command, args []string) {
+	if err := templates.Delete(args[0]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// promptTemplate is a template selected with 'prompt --template', rendered
+// with the variables set by --var.
+type promptTemplate struct {
+	*templates.Template
+	system string
+	prompt string
+}
+
+// loadPromptTemplate loads and renders the template selected by the
+// --template flag of cmd. It returns nil if no template was selected.
+func loadPromptTemplate(cmd *cobra.Command) *promptTemplate {
+	name := mustGetStringFlag(cmd, "template")
+	varArgs := mustGetStringArrayFlag(cmd, "var")
+	if name == "" {
+		if len(varArgs) > 0 {
+			log.Fatal("--var can only be used with --template")
+		}
+		return nil
+	}
+
+	vars := make(map[string]string)
+	for _, v := range varArgs {
+		key, value, found := strings.Cut(v, "=")
+		if !found || key == "" {
+			log.Fatalf("expect key=value for --var, got %q", v)
+		}
+		vars[key] = value
+	}
+
+	t, err := templates.Load(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	system, prompt, err := t.Render(vars)
+	if err != nil {
+		log.Fatalf("rendering template %s: %v", name, err)
+	}
+	return &promptTemplate{Template: t, system: system, prompt: prompt}
+}
+
+// newPromptModel creates the model for a prompt from the --model, --schema
+// and generation flags of cmd. If t is not nil, its model name, temperature
+// and schema are used unless overridden by the corresponding flags. It
+// returns the model's name, the model, and the response schema (or nil if
+// there's none).
+func newPromptModel(cmd *cobra.Command, client *genai.Client, t *templates.Template) (string, *generativeModel, *jsonschema.Schema) {
+	modelName := mustGetStringFlag(cmd, "model")
+	if t != nil && t.Model != "" && !cmd.Flags().Changed("model") {
+		modelName = t.Model
+	}
+	model, schema := newNamedPromptModel(cmd, client, t, modelName)
+	return modelName, model, schema
+}
+
+// newNamedPromptModel is like newPromptModel, but creates the model named
+// modelName regardless of the flags and t.
+func newNamedPromptModel(cmd *cobra.Command, client *genai.Client, t *templates.Template, modelName string) (*generativeModel, *jsonschema.Schema) {
+	model := newGenerativeModel(client, modelName)
+	if t != nil && t.Temperature != nil {
+		// --temp, if set, overrides this in applyGenerationFlags.
+		model.Temperature = genai.Ptr(*t.Temperature)
+	}
+	applyGenerationFlags(cmd, model)
+
+	var schema *jsonschema.Schema
+	if schemaPath := mustGetStringFlag(cmd, "schema"); schemaPath != "" {
+		var err error
+		schema, err = loadSchemaFile(schemaPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else if t != nil {
+		// The template's schema was validated when it was loaded.
+		schema, _ = t.ResponseSchema()
+	}
+	if schema != nil {
+		applyResponseSchema(model, schema)
+	}
+	return model, schema
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package commands
+
+import "os"
+
+// terminalWidth returns the width (in columns) of the terminal f is
+// connected to, or 0 if it's unknown.
+func terminalWidth(f *os.File) int {
+	return 0
+}
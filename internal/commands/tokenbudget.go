@@ -0,0 +1,273 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/genai"
+)
+
+// addTokenBudgetFlags adds the flags controlling the check of prompt sizes
+// before they're sent to cmd.
+func addTokenBudgetFlags(cmd *cobra.Command) {
+	cmd.Flags().Int("max-input-tokens", 0, "maximal number of input tokens in a request; 0 means the model's input token limit")
+	cmd.Flags().String("truncate", "", "shorten prompts over the token limit instead of refusing them: head (keep the beginning), tail (keep the end), middle (drop the middle) or drop-attachments")
+	cmd.Flags().String("token-check", "", "what to do with prompts over the token limit without --truncate: refuse, warn, or off (don't count tokens before sending); by default, refuse with --max-input-tokens or --truncate, and off otherwise")
+}
+
+// Strategies of --truncate.
+const (
+	truncateHead            = "head"
+	truncateTail            = "tail"
+	truncateMiddle          = "middle"
+	truncateDropAttachments = "drop-attachments"
+)
+
+// tokenBudget checks the number of input tokens of requests before they're
+// sent, against the input token limit of the model and --max-input-tokens.
+type tokenBudget struct {
+	maxTokens int32
+	truncate  string
+	warnOnly  bool
+
+	// limit is the effective limit, and limitDesc describes where it comes
+	// from. They're set by the first check.
+	limit     int32
+	limitDesc string
+
+	// overhead is the number of tokens the model's configuration (like its
+	// system instruction and tools) adds to every request; it's counted when
+	// it's first needed, and is -1 until then.
+	overhead int32
+}
+
+// newTokenBudget creates a tokenBudget configured by the flags of cmd (see
+// addTokenBudgetFlags). It returns nil if the check is turned off.
+func newTokenBudget(cmd *cobra.Command) *tokenBudget {
+	b := &tokenBudget{
+		maxTokens: int32(mustGetIntFlag(cmd, "max-input-tokens")),
+		truncate:  mustGetStringFlag(cmd, "truncate"),
+		overhead:  -1,
+	}
+	switch b.truncate {
+	case "", truncateHead, truncateTail, truncateMiddle, truncateDropAttachments:
+	default:
+		log.Fatalf("expect --truncate to be head, tail, middle or drop-attachments, got %q", b.truncate)
+	}
+	if b.maxTokens < 0 {
+		log.Fatal("expect --max-input-tokens to be non-negative")
+	}
+
+	check := mustGetStringFlag(cmd, "token-check")
+	if check == "" {
+		// Counting tokens takes extra API calls for every request, so by
+		// default it's only done when a limit or truncation was asked for.
+		check = "off"
+		if b.truncate != "" || b.maxTokens > 0 {
+			check = "refuse"
+		}
+	}
+	switch check {
+	case "refuse":
+	case "warn":
+		b.warnOnly = true
+	case "off":
+		if b.truncate != "" || b.maxTokens > 0 {
+			log.Fatal("--truncate and --max-input-tokens can't be used with --token-check=off")
+		}
+		return nil
+	default:
+		log.Fatalf("expect --token-check to be refuse, warn or off, got %q", check)
+	}
+	return b
+}
+
+// check counts the input tokens of a request sending parts to model, after
+// the given chat history. If they're over the limit, parts are truncated
+// with the --truncate strategy, if one was chosen; otherwise, an error is
+// returned (or a warning is printed, with --token-check=warn). It returns
+// the parts to send.
+func (b *tokenBudget) check(ctx context.Context, model *generativeModel, history []*genai.Content, parts []*genai.Part) ([]*genai.Part, error) {
+	if b == nil {
+		return parts, nil
+	}
+	if b.limitDesc == "" {
+		b.setLimit(ctx, model)
+	}
+	if b.limit <= 0 {
+		return parts, nil
+	}
+
+	overhead, err := b.countOverhead(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+	count := func(parts []*genai.Part) (int32, error) {
+		contents := append(slices.Clip(history), genai.NewContentFromParts(parts, genai.RoleUser))
+		total, err := model.countTokens(ctx, contents...)
+		if err != nil {
+			return 0, fmt.Errorf("counting prompt tokens: %w", err)
+		}
+		return total + overhead, nil
+	}
+
+	total, err := count(parts)
+	if err != nil {
+		return nil, err
+	}
+	if total <= b.limit {
+		return parts, nil
+	}
+	if b.truncate == "" {
+		if b.warnOnly {
+			fmt.Fprintf(os.Stderr, "warning: prompt has %d input tokens, over %s\n", total, b.limitDesc)
+			return parts, nil
+		}
+		return nil, fmt.Errorf("prompt has %d input tokens, over %s (see --truncate)", total, b.limitDesc)
+	}
+
+	// Truncation is approximate, since tokens don't map to a fixed number of
+	// characters; it's repeated a few times until the prompt fits.
+	origTotal := total
+	for attempt := 0; total > b.limit; attempt++ {
+		if attempt == 4 {
+			return nil, fmt.Errorf("unable to truncate prompt to %s", b.limitDesc)
+		}
+		if b.truncate == truncateDropAttachments {
+			parts = slices.DeleteFunc(slices.Clone(parts), func(p *genai.Part) bool {
+				return !isTextPart(p)
+			})
+		} else {
+			parts, err = b.truncateText(ctx, model, parts, total-b.limit)
+			if err != nil {
+				return nil, err
+			}
+		}
+		newTotal, err := count(parts)
+		if err != nil {
+			return nil, err
+		}
+		if newTotal == total {
+			return nil, fmt.Errorf("prompt has %d input tokens, over %s, and --truncate %s can't shorten it", total, b.limitDesc, b.truncate)
+		}
+		total = newTotal
+	}
+	fmt.Fprintf(os.Stderr, "warning: truncated prompt from %d to %d input tokens (--truncate %s)\n", origTotal, total, b.truncate)
+	return parts, nil
+}
+
+// setLimit sets the effective limit from the model's input token limit and
+// --max-input-tokens.
+func (b *tokenBudget) setLimit(ctx context.Context, model *generativeModel) {
+	if b.maxTokens > 0 {
+		b.limit = b.maxTokens
+		b.limitDesc = fmt.Sprintf("the --max-input-tokens limit of %d", b.maxTokens)
+	}
+	info, err := model.info(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: unable to get the input token limit of the model: %v\n", err)
+		if b.limitDesc == "" {
+			b.limitDesc = "none"
+		}
+		return
+	}
+	if info.InputTokenLimit > 0 && (b.limit <= 0 || info.InputTokenLimit < b.limit) {
+		b.limit = info.InputTokenLimit
+		b.limitDesc = fmt.Sprintf("the input token limit of %d for %s", info.InputTokenLimit, info.Name)
+	}
+	if b.limitDesc == "" {
+		b.limitDesc = "none"
+	}
+}
+
+// truncateText shortens the longest text part of parts by approximately
+// excess tokens, with the --truncate strategy.
+func (b *tokenBudget) truncateText(ctx context.Context, model *generativeModel, parts []*genai.Part, excess int32) ([]*genai.Part, error) {
+	longest := -1
+	for i, p := range parts {
+		if isTextPart(p) && (longest < 0 || len(p.Text) > len(parts[longest].Text)) {
+			longest = i
+		}
+	}
+	if longest < 0 {
+		return nil, fmt.Errorf("prompt is over %s, and has no text to truncate", b.limitDesc)
+	}
+
+	// The text is counted on its own, without the model's configuration,
+	// which truncating it doesn't change.
+	text := []rune(parts[longest].Text)
+	total, err := model.countTokens(ctx, genai.NewContentFromParts(parts[longest:longest+1], genai.RoleUser))
+	if err != nil {
+		return nil, fmt.Errorf("counting prompt tokens: %w", err)
+	}
+	// Leave a small margin, to make it likely that a single truncation is
+	// enough.
+	keep := float64(total-excess) / float64(total) * 0.98
+	if keep <= 0 {
+		return nil, fmt.Errorf("prompt is over %s even without its longest text", b.limitDesc)
+	}
+	n := int(float64(len(text)) * keep)
+
+	var truncated string
+	switch b.truncate {
+	case truncateHead:
+		truncated = string(text[:n])
+	case truncateTail:
+		truncated = string(text[len(text)-n:])
+	case truncateMiddle:
+		truncated = string(text[:n/2]) + "\n[...]\n" + string(text[len(text)-(n-n/2):])
+	}
+
+	parts = slices.Clone(parts)
+	parts[longest] = genai.NewPartFromText(truncated)
+	return parts, nil
+}
+
+// countOverhead returns the number of tokens that the configuration of model
+// (its system instruction, tools and cached content) adds to every request.
+// The Gemini API only counts the tokens of contents, so the system
+// instruction and the declarations of the tools are counted as contents of
+// their own, which approximates how they're counted in requests. Cached
+// content reports its own number of tokens.
+func (b *tokenBudget) countOverhead(ctx context.Context, model *generativeModel) (int32, error) {
+	if b.overhead >= 0 {
+		return b.overhead, nil
+	}
+
+	var contents []*genai.Content
+	if si := model.SystemInstruction; si != nil {
+		contents = append(contents, genai.NewContentFromParts(si.Parts, genai.RoleUser))
+	}
+	if len(model.Tools) > 0 {
+		data, err := json.Marshal(model.Tools)
+		if err != nil {
+			return 0, err
+		}
+		contents = append(contents, genai.NewContentFromText(string(data), genai.RoleUser))
+	}
+
+	var overhead int32
+	if len(contents) > 0 {
+		n, err := model.countTokens(ctx, contents...)
+		if err != nil {
+			return 0, fmt.Errorf("counting prompt tokens: %w", err)
+		}
+		overhead += n
+	}
+	if model.CachedContent != "" {
+		cc, err := model.client.Caches.Get(ctx, model.CachedContent, nil)
+		if err != nil {
+			return 0, fmt.Errorf("counting cached content tokens: %w", err)
+		}
+		if cc.UsageMetadata != nil {
+			overhead += cc.UsageMetadata.TotalTokenCount
+		}
+	}
+	b.overhead = overhead
+	return overhead, nil
+}
@@ -0,0 +1,195 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/eliben/gemini-cli/internal/tools"
+	"github.com/spf13/cobra"
+	"google.golang.org/genai"
+)
+
+// maxToolRounds is the maximal number of consecutive rounds of function
+// calls we run for a single message, before giving up on the model ever
+// producing a final answer.
+const maxToolRounds = 16
+
+// addToolsFlags adds the flags for function calling with local tools to cmd.
+func addToolsFlags(cmd *cobra.Command) {
+	cmd.Flags().String("tools", "", "path of a YAML file declaring local tools the model can call")
+	cmd.Flags().Bool("yes", false, "run the tools the model calls without asking for confirmation")
+}
+
+// toolRunner runs the local tools declared with --tools when the model asks
+// for them with function calls.
+type toolRunner struct {
+	tools map[string]*tools.Tool
+
+	// httpClient calls the tools implemented by HTTP endpoints, through the
+	// --proxy of API requests.
+	httpClient *http.Client
+
+	// Unless autoConfirm is set, every call has to be confirmed by the user,
+	// reading the answer from in. This requires an interactive terminal.
+	autoConfirm bool
+	interactive bool
+	in          *bufio.Reader
+}
+
+// newToolRunner creates a toolRunner from the flags added with addToolsFlags,
+// reading confirmations from in. It returns nil if no tools were declared.
+func newToolRunner(cmd *cobra.Command, in *bufio.Reader) *toolRunner {
+	path := mustGetStringFlag(cmd, "tools")
+	if path == "" {
+		return nil
+	}
+
+	declared, err := tools.LoadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Every tool has its own timeout, so the client has none.
+	httpClient, err := newHTTPClient(cmd, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tr := &toolRunner{
+		tools:       make(map[string]*tools.Tool),
+		httpClient:  httpClient,
+		autoConfirm: mustGetBoolFlag(cmd, "yes"),
+		interactive: isTerminal(os.Stdin),
+		in:          in,
+	}
+	for _, t := range declared {
+		tr.tools[t.Name] = t
+	}
+	return tr
+}
+
+// declarations returns the declarations of the runner's tools, to set on a
+// model.
+func (tr *toolRunner) declarations() []*genai.Tool {
+	var decls []*genai.FunctionDeclaration
+	for _, name := range slices.Sorted(maps.Keys(tr.tools)) {
+		t := tr.tools[name]
+		decls = append(decls, &genai.FunctionDeclaration{
+			Name:        t.Name,
+			Description: t.Description,
+			Parameters:  toGenaiSchema(t.Schema),
+		})
+	}
+	return []*genai.Tool{{FunctionDeclarations: decls}}
+}
+
+// run runs the function call fc and returns the response to send back to
+// the model. Failures (including the user declining to run the tool) are
+// reported to the model in the response, so it can decide how to proceed.
+func (tr *toolRunner) run(ctx context.Context, fc *genai.FunctionCall) *genai.FunctionResponse {
+	argsJSON, _ := json.Marshal(fc.Args)
+	fmt.Fprintf(os.Stderr, "[tool call] %s(%s)\n", fc.Name, argsJSON)
+
+	errorResponse := func(msg string) *genai.FunctionResponse {
+		fmt.Fprintf(os.Stderr, "[tool error] %s\n", msg)
+		return &genai.FunctionResponse{ID: fc.ID, Name: fc.Name, Response: map[string]any{"error": msg}}
+	}
+
+	t, ok := tr.tools[fc.Name]
+	if !ok {
+		return errorResponse(fmt.Sprintf("no tool named %q", fc.Name))
+	}
+	if !tr.confirm(fc) {
+		return errorResponse("the user declined to run this tool")
+	}
+
+	result, err := t.Call(ctx, tr.httpClient, fc.Args)
+	if err != nil {
+		return errorResponse(err.Error())
+	}
+	return &genai.FunctionResponse{ID: fc.ID, Name: fc.Name, Response: result}
+}
+
+// confirm asks the user whether to run fc, and returns the answer.
+func (tr *toolRunner) confirm(fc *genai.FunctionCall) bool {
+	if tr.autoConfirm {
+		return true
+	}
+	if !tr.interactive {
+		log.Fatalf("tool call to %s needs confirmation, but standard input is not a terminal; use --yes to run tools without confirmation", fc.Name)
+	}
+
+	fmt.Fprintf(os.Stderr, "Run %s? [y/N] ", fc.Name)
+	answer, err := tr.in.ReadString('\n')
+	if err != nil && err != io.EOF {
+		log.Fatal(err)
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// sendMessage sends parts to the model in session, and returns the response.
+// If out is not nil, the response is streamed and presented with out as it
+// arrives. If tr is not nil, function calls from the model are run
+// with tr and their results are sent back, until the model responds without
+// calling any more functions; the final response is returned.
+func sendMessage(ctx context.Context, session *chatSession, parts []*genai.Part, out streamOutput, tr *toolRunner) (*genai.GenerateContentResponse, error) {
+	for round := 0; ; round++ {
+		var resp *genai.GenerateContentResponse
+		var err error
+		if out != nil {
+			resp, err = session.sendMessageStream(ctx, out, parts...)
+		} else {
+			resp, err = session.sendMessage(ctx, parts...)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if tr == nil || resp == nil || len(resp.Candidates) == 0 {
+			return resp, nil
+		}
+		// Chat sessions always ask for a single candidate, and only the first
+		// candidate is added to the session's history, so it's the only one
+		// whose function calls can be answered.
+		if len(resp.Candidates) > 1 {
+			return nil, fmt.Errorf("expect a single candidate in a response with function calls, got %d", len(resp.Candidates))
+		}
+		var calls []*genai.FunctionCall
+		if content := resp.Candidates[0].Content; content != nil {
+			for _, part := range content.Parts {
+				if part.FunctionCall != nil {
+					calls = append(calls, part.FunctionCall)
+				}
+			}
+		}
+		if len(calls) == 0 {
+			return resp, nil
+		}
+		if round >= maxToolRounds {
+			return nil, fmt.Errorf("model is still calling tools after %d rounds", maxToolRounds)
+		}
+
+		parts = nil
+		for _, fc := range calls {
+			parts = append(parts, &genai.Part{FunctionResponse: tr.run(ctx, fc)})
+		}
+	}
+}
+
+// isTerminal reports whether f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,413 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/eliben/gemini-cli/internal/mediatype"
+	"github.com/eliben/gemini-cli/internal/uploads"
+	"github.com/spf13/cobra"
+	"google.golang.org/genai"
+)
+
+var uploadCmd = &cobra.Command{
+	Use:   "upload",
+	Short: "Manage files uploaded with the Gemini File API",
+	Long:  strings.TrimSpace(uploadUsage),
+
+	// 'upload' is a parent of subcommands, and doesn't do anything on its own.
+	// Therefore we don't define a Run: function for it.
+}
+
+var uploadUsage = `
+Manage files uploaded with the Gemini File API.
+
+Uploaded files can be referred to in prompts without sending their contents
+with every request, and can be much larger than inline data. 'prompt' and
+'chat' upload large files automatically (see their --upload-threshold flag).
+The File API deletes uploaded files after 48 hours.
+
+Uploads are tracked locally by the hash of their content, so the same
+content isn't uploaded again while an earlier upload is still alive.
+`
+
+var uploadPutCmd = &cobra.Command{
+	Use:   "put <path>...",
+	Short: "Upload files",
+	Args:  cobra.MinimumNArgs(1),
+	Run:   runUploadPutCmd,
+}
+
+var uploadListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List uploaded files",
+	Args:    cobra.NoArgs,
+	Run:     runUploadListCmd,
+}
+
+var uploadGetCmd = &cobra.Command{
+	Use:   "get <name>",
+	Short: "Show information about an uploaded file",
+	Args:  cobra.ExactArgs(1),
+	Run:   runUploadGetCmd,
+}
+
+var uploadDeleteCmd = &cobra.Command{
+	Use:     "delete <name>...",
+	Aliases: []string{"rm"},
+	Short:   "Delete uploaded files",
+	Args:    cobra.MinimumNArgs(1),
+	Run:     runUploadDeleteCmd,
+}
+
+func init() {
+	rootCmd.AddCommand(uploadCmd)
+	uploadCmd.AddCommand(uploadPutCmd)
+	uploadCmd.AddCommand(uploadListCmd)
+	uploadCmd.AddCommand(uploadGetCmd)
+	uploadCmd.AddCommand(uploadDeleteCmd)
+
+	uploadPutCmd.Flags().String("display-name", "", "display name of the uploaded file; defaults to the file's base name")
+}
+
+// defaultUploadThreshold is the default size (in MiB) of files above which
+// files are uploaded with the File API rather than sent inline. Requests
+// with inline data are limited to 20 MB in total.
+const defaultUploadThreshold = 15
+
+// addUploadFlags adds the flags controlling automatic uploads to cmd.
+func addUploadFlags(cmd *cobra.Command) {
+	cmd.Flags().Int("upload-threshold", defaultUploadThreshold, "files larger than this many MiB are uploaded with the File API instead of being sent inline")
+}
+
+func runUploadPutCmd(cmd *cobra.Command, args []string) {
+	displayName := mustGetStringFlag(cmd, "display-name")
+	if displayName != "" && len(args) > 1 {
+		log.Fatal("--display-name can only be used when uploading a single file")
+	}
+
+	ctx := context.Background()
+	client, err := newGenaiClient(ctx, cmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+	u := newFileUploader(cmd, client)
+
+	for _, path := range args {
+		name := displayName
+		if name == "" {
+			name = filepath.Base(path)
+		}
+		f, err := u.upload(ctx, path, name)
+		if err != nil {
+			log.Fatalf("uploading %s: %v", path, err)
+		}
+		fmt.Printf("%s\t%s\n", f.Name, f.URI)
+	}
+}
+
+func runUploadListCmd(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	client, err := newGenaiClient(ctx, cmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for f, err := range client.Files.All(ctx) {
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", f.Name, f.DisplayName, f.MIMEType, formatSize(fileSize(f)), fileStateName(f.State))
+	}
+	w.Flush()
+}
+
+func runUploadGetCmd(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	client, err := newGenaiClient(ctx, cmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	f, err := client.Files.Get(ctx, args[0], nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("name:         %s\n", f.Name)
+	fmt.Printf("display name: %s\n", f.DisplayName)
+	fmt.Printf("uri:          %s\n", f.URI)
+	fmt.Printf("mime type:    %s\n", f.MIMEType)
+	fmt.Printf("size:         %s\n", formatSize(fileSize(f)))
+	fmt.Printf("state:        %s\n", fileStateName(f.State))
+	fmt.Printf("created:      %s\n", f.CreateTime.Local().Format(time.DateTime))
+	if !f.ExpirationTime.IsZero() {
+		fmt.Printf("expires:      %s\n", f.ExpirationTime.Local().Format(time.DateTime))
+	}
+	if f.Error != nil {
+		fmt.Printf("error:        %s\n", f.Error.Message)
+	}
+}
+
+func runUploadDeleteCmd(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	client, err := newGenaiClient(ctx, cmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tracker := openUploadTracker()
+	if tracker != nil {
+		defer tracker.Close()
+	}
+	for _, name := range args {
+		if _, err := client.Files.Delete(ctx, name, nil); err != nil {
+			log.Fatalf("deleting %s: %v", name, err)
+		}
+		if tracker != nil {
+			if err := tracker.Remove(name); err != nil {
+				warnUploadTrackerFailure(err)
+			}
+		}
+	}
+}
+
+// fileUploader creates prompt parts from files, uploading files above a
+// size threshold with the File API.
+type fileUploader struct {
+	cmd       *cobra.Command
+	threshold int64
+
+	// client is used for uploads; if it's nil, a client is created when the
+	// first file is uploaded.
+	client *genai.Client
+}
+
+// newFileUploader creates a fileUploader configured by the flags of cmd (the
+// threshold is taken from the flag added with addUploadFlags, if cmd has it).
+// client may be nil, in which case a client is created if it's needed.
+func newFileUploader(cmd *cobra.Command, client *genai.Client) *fileUploader {
+	threshold := int64(defaultUploadThreshold)
+	if cmd.Flags().Lookup("upload-threshold") != nil {
+		threshold = int64(mustGetIntFlag(cmd, "upload-threshold"))
+	}
+	return &fileUploader{cmd: cmd, threshold: threshold << 20, client: client}
+}
+
+// partFromFile creates a prompt part from the file at path. Files up to the
+// threshold are sent inline (see getPartFromFile); larger files are uploaded
+// and referred to with a FileData part.
+func (u *fileUploader) partFromFile(ctx context.Context, path string) (*genai.Part, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if fi.Size() <= u.threshold {
+		return getPartFromFile(path)
+	}
+
+	f, err := u.upload(ctx, path, filepath.Base(path))
+	if err != nil {
+		return nil, fmt.Errorf("uploading %s: %w", path, err)
+	}
+	return genai.NewPartFromURI(f.URI, f.MIMEType), nil
+}
+
+// upload uploads the file at path, and waits for the uploaded file to be
+// ready for use. If the same content was uploaded earlier and that file is
+// still alive, it's returned instead of uploading again. The file is streamed
+// rather than read into memory, since uploaded files can be very large.
+func (u *fileUploader) upload(ctx context.Context, path string, displayName string) (*genai.File, error) {
+	r, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	head := make([]byte, mediatype.SniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	head = head[:n]
+	mediaType := mediatype.DetectHead(path, head)
+	if !mediatype.IsText(mediaType) && !mediatype.IsSupported(mediaType) {
+		return nil, fmt.Errorf("%s: unsupported file type (%s)", path, mediaType)
+	}
+
+	// The content is hashed in a first pass, to look up earlier uploads of
+	// it before uploading.
+	hash, size, err := uploads.HashReader(io.MultiReader(bytes.NewReader(head), r))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	client, err := u.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tracker := openUploadTracker()
+	if tracker != nil {
+		defer tracker.Close()
+
+		// An upload is only reused if it won't expire in the middle of using
+		// it.
+		rec, err := tracker.Lookup(hash, time.Hour)
+		if err != nil {
+			warnUploadTrackerFailure(err)
+		} else if rec != nil {
+			if f, err := client.Files.Get(ctx, rec.Name, nil); err == nil && f.State != genai.FileStateFailed {
+				return waitForFile(ctx, client, f)
+			}
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "uploading %s (%s)...\n", displayName, formatSize(size))
+	f, err := client.Files.Upload(ctx, r, &genai.UploadFileConfig{
+		DisplayName: displayName,
+		MIMEType:    mediaType,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if f, err = waitForFile(ctx, client, f); err != nil {
+		return nil, err
+	}
+
+	if tracker != nil {
+		expires := f.ExpirationTime
+		if expires.IsZero() {
+			expires = f.CreateTime.Add(48 * time.Hour)
+		}
+		err := tracker.Add(&uploads.Upload{
+			SHA256:   hash,
+			Name:     f.Name,
+			URI:      f.URI,
+			MIMEType: f.MIMEType,
+			Size:     fileSize(f),
+			Expires:  expires,
+		})
+		if err != nil {
+			warnUploadTrackerFailure(err)
+		}
+	}
+	return f, nil
+}
+
+func (u *fileUploader) getClient(ctx context.Context) (*genai.Client, error) {
+	if u.client == nil {
+		client, err := newGenaiClient(ctx, u.cmd)
+		if err != nil {
+			return nil, err
+		}
+		u.client = client
+	}
+	return u.client, nil
+}
+
+// Polling of uploaded files that are being processed.
+const (
+	fileProcessingPollInterval = 2 * time.Second
+	fileProcessingTimeout      = 10 * time.Minute
+)
+
+// waitForFile waits for the uploaded file f to finish processing, and
+// returns its final state. It fails if processing fails, or doesn't finish
+// within fileProcessingTimeout.
+func waitForFile(ctx context.Context, client *genai.Client, f *genai.File) (*genai.File, error) {
+	ctx, cancel := context.WithTimeout(ctx, fileProcessingTimeout)
+	defer cancel()
+
+	reported := false
+	for f.State == genai.FileStateProcessing {
+		if !reported {
+			fmt.Fprintf(os.Stderr, "waiting for %s to be processed...\n", f.Name)
+			reported = true
+		}
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, fmt.Errorf("%s wasn't processed within %s", f.Name, fileProcessingTimeout)
+			}
+			return nil, ctx.Err()
+		case <-time.After(fileProcessingPollInterval):
+		}
+
+		var err error
+		if f, err = client.Files.Get(ctx, f.Name, nil); err != nil {
+			return nil, err
+		}
+	}
+	if f.State == genai.FileStateFailed {
+		return nil, fmt.Errorf("processing %s failed: %s", f.Name, fileErrorMessage(f))
+	}
+	return f, nil
+}
+
+// openUploadTracker opens the database tracking uploads. Failures are
+// reported as warnings, returning nil.
+func openUploadTracker() *uploads.Tracker {
+	path, err := uploads.DefaultPath()
+	if err != nil {
+		warnUploadTrackerFailure(err)
+		return nil
+	}
+	tracker, err := uploads.Open(path)
+	if err != nil {
+		warnUploadTrackerFailure(err)
+		return nil
+	}
+	return tracker
+}
+
+func warnUploadTrackerFailure(err error) {
+	fmt.Fprintf(os.Stderr, "warning: tracking uploads: %v\n", err)
+}
+
+func fileStateName(s genai.FileState) string {
+	return apiEnumName(s, "STATE_")
+}
+
+// fileSize returns the size of f in bytes, or 0 if it's unknown.
+func fileSize(f *genai.File) int64 {
+	if f.SizeBytes == nil {
+		return 0
+	}
+	return *f.SizeBytes
+}
+
+// fileErrorMessage returns the message of the error processing f, if any.
+func fileErrorMessage(f *genai.File) string {
+	if f.Error == nil {
+		return "unknown error"
+	}
+	return f.Error.Message
+}
+
+// formatSize formats a size in bytes for humans.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
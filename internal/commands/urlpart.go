@@ -0,0 +1,161 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/eliben/gemini-cli/internal/htmltext"
+	"github.com/eliben/gemini-cli/internal/mediatype"
+	"github.com/spf13/cobra"
+	"golang.org/x/net/html/charset"
+	"google.golang.org/genai"
+)
+
+// Defaults for fetching URLs in prompts.
+const (
+	defaultURLTimeout = 30 * time.Second
+	defaultMaxURLSize = 20 // MiB
+)
+
+// addURLFlags adds the flags controlling how URLs in prompts are fetched to
+// cmd.
+func addURLFlags(cmd *cobra.Command) {
+	cmd.Flags().Duration("url-timeout", defaultURLTimeout, "timeout for fetching each URL in the prompt")
+	cmd.Flags().Int("max-url-size", defaultMaxURLSize, "maximal size (in MiB) of the content fetched from a URL in the prompt")
+}
+
+// urlFetcher creates prompt parts from the content of URLs.
+type urlFetcher struct {
+	client  *http.Client
+	maxSize int64
+}
+
+// newURLFetcher creates a urlFetcher configured by the flags of cmd (see
+// addURLFlags). Fetches go through the same proxy as requests to the API.
+func newURLFetcher(cmd *cobra.Command) (*urlFetcher, error) {
+	client, err := newHTTPClient(cmd, mustGetDurationFlag(cmd, "url-timeout"))
+	if err != nil {
+		return nil, err
+	}
+	return &urlFetcher{client: client, maxSize: int64(mustGetIntFlag(cmd, "max-url-size")) << 20}, nil
+}
+
+// part fetches rawURL and creates a prompt part from its content. HTML pages
+// are converted to their readable text, other textual content is sent as
+// is, and content of media types models accept (like images and PDFs) is
+// sent as a blob.
+func (f *urlFetcher) part(ctx context.Context, rawURL string) (*genai.Part, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("fetching %s: %s", rawURL, resp.Status)
+	}
+	if resp.ContentLength > f.maxSize {
+		return nil, f.tooLarge(rawURL)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, f.maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", rawURL, err)
+	}
+	if int64(len(data)) > f.maxSize {
+		return nil, f.tooLarge(rawURL)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = ""
+	}
+
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		r, err := charset.NewReader(bytes.NewReader(data), contentType)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", rawURL, err)
+		}
+		text, err := htmltext.Convert(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", rawURL, err)
+		}
+		return genai.NewPartFromText(text), nil
+	case isTextualMediaType(mediaType):
+		text, err := decodeText(data, params["charset"])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", rawURL, err)
+		}
+		return genai.NewPartFromText(text), nil
+	}
+
+	// Servers often report a generic type (or none at all) for media files;
+	// in that case, the type is detected from the content.
+	mt := mediatype.Normalize(mediaType)
+	if !mediatype.IsSupported(mt) {
+		var urlPath string
+		if u, err := url.Parse(rawURL); err == nil {
+			urlPath = path.Base(u.Path)
+		}
+		mt = mediatype.Detect(urlPath, data)
+	}
+	switch {
+	case mediatype.IsText(mt):
+		return genai.NewPartFromText(mediatype.DecodeText(data)), nil
+	case mediatype.IsSupported(mt):
+		return genai.NewPartFromBytes(data, mt), nil
+	}
+	if contentType == "" {
+		contentType = mt
+	}
+	return nil, fmt.Errorf("%s: unsupported content type (%s)", rawURL, contentType)
+}
+
+func (f *urlFetcher) tooLarge(rawURL string) error {
+	return fmt.Errorf("%s: content is larger than %d MiB (see --max-url-size)", rawURL, f.maxSize>>20)
+}
+
+// isTextualMediaType reports whether content of the given media type is
+// text that can be sent to models as is.
+func isTextualMediaType(mediaType string) bool {
+	switch {
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return true
+	case mediaType == "application/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	return false
+}
+
+// decodeText decodes data from the named charset to a string. Without a
+// name, the encoding is guessed like for text files (see
+// [mediatype.DecodeText]).
+func decodeText(data []byte, charsetName string) (string, error) {
+	if charsetName == "" {
+		return mediatype.DecodeText(data), nil
+	}
+	r, err := charset.NewReaderLabel(charsetName, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,418 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/eliben/gemini-cli/internal/config"
+	"github.com/eliben/gemini-cli/internal/pricing"
+	"github.com/eliben/gemini-cli/internal/promptlog"
+	"github.com/spf13/cobra"
+	"google.golang.org/genai"
+)
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Report token usage and estimated costs",
+	Long:  strings.TrimSpace(usageUsage),
+
+	// 'usage' is a parent of subcommands, and doesn't do anything on its own.
+	// Therefore we don't define a Run: function for it.
+}
+
+var usageUsage = `
+Report token usage and estimated costs.
+
+Costs are estimated from the number of tokens used, with a table of prices
+per million tokens for each model. The built-in prices are the pay-as-you-go
+prices of the Gemini API at the time of writing; they can be overridden (or
+prices added for other models) in the 'pricing' section of the config file:
+
+  pricing:
+    gemini-2.5-pro:
+      input: 1.25
+      output: 10.00
+      cached_input: 0.125
+      long_context_threshold: 200000
+      long_input: 2.50
+      long_output: 15.00
+      long_cached_input: 0.25
+
+Models without a price of their own use the price of the model they extend
+with a suffix; for example, gemini-2.0-flash-001 uses the price of
+gemini-2.0-flash. Other models, like models released after the built-in
+prices were written, get no cost estimate until their price is added to the
+config file. Storage costs of cached content aren't included.
+
+To print the usage and cost of a single command, pass it --usage.
+`
+
+var usageReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Report usage and estimated costs from the log of prompts and responses",
+	Long: strings.TrimSpace(`
+Report the token usage and estimated costs of the exchanges with models
+recorded in the log (see the 'logs' command), grouped by day, model and
+command by default. Exchanges of 'prompt batch' and embeddings aren't logged,
+so they aren't included; use --usage with these commands to see their usage.
+
+For example, to report the costs of September 2024 by model:
+
+  gemini-cli usage report --month 2024-09 --by model
+`),
+	Args: cobra.NoArgs,
+	Run:  runUsageReportCmd,
+}
+
+func init() {
+	rootCmd.AddCommand(usageCmd)
+	usageCmd.AddCommand(usageReportCmd)
+
+	usageReportCmd.Flags().String("since", "", "only report exchanges from this date on (YYYY-MM-DD)")
+	usageReportCmd.Flags().String("until", "", "only report exchanges before this date (YYYY-MM-DD)")
+	usageReportCmd.Flags().String("month", "", "only report exchanges in this month (YYYY-MM)")
+	usageReportCmd.Flags().StringSlice("by", []string{"day", "model", "command"}, "what to group usage by: any of day, month, model and command")
+	usageReportCmd.Flags().Bool("json", false, "print the report as JSON")
+}
+
+// addUsageFlags adds the flag for reporting token usage to cmd.
+func addUsageFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("usage", false, "print the number of tokens used and the estimated cost to stderr")
+}
+
+// usageMeter reports the token usage and estimated cost of requests to a
+// writer. Its methods are safe for concurrent use. A nil *usageMeter doesn't
+// report anything.
+type usageMeter struct {
+	w      io.Writer
+	prices pricing.Table
+
+	mu sync.Mutex
+	// totals has the usage added with add for each model, along with the
+	// number of requests.
+	totals map[string]*usageTotal
+}
+
+type usageTotal struct {
+	requests int
+	usage    pricing.Usage
+
+	// unreported is set if the usage of some of the requests is unknown.
+	unreported bool
+}
+
+// newUsageMeter creates a usageMeter reporting to stderr if --usage was
+// passed to cmd, and returns nil otherwise.
+func newUsageMeter(cmd *cobra.Command) *usageMeter {
+	if !mustGetBoolFlag(cmd, "usage") {
+		return nil
+	}
+	return &usageMeter{w: os.Stderr, prices: loadPrices(), totals: make(map[string]*usageTotal)}
+}
+
+// loadPrices returns the built-in price table, with the overrides of the
+// config file.
+func loadPrices() pricing.Table {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return pricing.Default.WithOverrides(cfg.Pricing)
+}
+
+// responseUsage returns the token usage reported in resp. The tokens of the
+// model's thoughts are billed as output, so they count as response tokens.
+func responseUsage(resp *genai.GenerateContentResponse) pricing.Usage {
+	if resp == nil || resp.UsageMetadata == nil {
+		return pricing.Usage{}
+	}
+	um := resp.UsageMetadata
+	return pricing.Usage{
+		PromptTokens:   um.PromptTokenCount,
+		CachedTokens:   um.CachedContentTokenCount,
+		ResponseTokens: um.CandidatesTokenCount + um.ThoughtsTokenCount,
+	}
+}
+
+// report writes the usage u of a request to the model named modelName.
+func (m *usageMeter) report(modelName string, u pricing.Usage) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fmt.Fprintf(m.w, "usage of %s: %s\n", modelName, m.describe(modelName, u))
+}
+
+// add adds the usage u of a request to the model named modelName to the
+// totals reported by reportTotals.
+func (m *usageMeter) add(modelName string, u pricing.Usage) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := m.totals[modelName]
+	if t == nil {
+		t = &usageTotal{}
+		m.totals[modelName] = t
+	}
+	t.requests++
+	t.usage.PromptTokens += u.PromptTokens
+	t.usage.CachedTokens += u.CachedTokens
+	t.usage.ResponseTokens += u.ResponseTokens
+}
+
+// reportTotals writes the total usage added with add, for each model.
+func (m *usageMeter) reportTotals() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, name := range slices.Sorted(maps.Keys(m.totals)) {
+		t := m.totals[name]
+		requests := "requests"
+		if t.requests == 1 {
+			requests = "request"
+		}
+		if t.unreported {
+			fmt.Fprintf(m.w, "usage of %s (%d %s): %s\n", name, t.requests, requests, unreportedEmbeddingUsage)
+			continue
+		}
+		fmt.Fprintf(m.w, "usage of %s (%d %s): %s\n", name, t.requests, requests, m.describe(name, t.usage))
+	}
+}
+
+// describe returns a description of the usage u of the model named
+// modelName, with its estimated cost.
+func (m *usageMeter) describe(modelName string, u pricing.Usage) string {
+	s := fmt.Sprintf("%d prompt tokens (%d cached) + %d response tokens = %d tokens",
+		u.PromptTokens, u.CachedTokens, u.ResponseTokens, u.PromptTokens+u.ResponseTokens)
+	if price, ok := m.prices.Lookup(modelName); ok {
+		s += fmt.Sprintf("; estimated cost $%.6f", price.Cost(u))
+	} else {
+		s += "; no price for this model (see 'gemini-cli help usage')"
+	}
+	return s
+}
+
+// embeddingUsage returns the token usage reported in resp, and whether it
+// was reported at all: only Vertex AI reports the number of tokens embedded,
+// and the Gemini API doesn't.
+func embeddingUsage(resp *genai.EmbedContentResponse) (pricing.Usage, bool) {
+	var u pricing.Usage
+	reported := false
+	for _, e := range resp.Embeddings {
+		if e != nil && e.Statistics != nil {
+			u.PromptTokens += int32(e.Statistics.TokenCount)
+			reported = true
+		}
+	}
+	return u, reported
+}
+
+// reportEmbedding writes the usage of the embedding request to the model
+// named modelName that got resp, or that it's unknown if resp doesn't report
+// it.
+func (m *usageMeter) reportEmbedding(modelName string, resp *genai.EmbedContentResponse) {
+	if u, ok := embeddingUsage(resp); ok {
+		m.report(modelName, u)
+	} else if m != nil {
+		fmt.Fprintf(m.w, "usage of %s: %s\n", modelName, unreportedEmbeddingUsage)
+	}
+}
+
+// addEmbedding is like add, for an embedding request that got resp.
+func (m *usageMeter) addEmbedding(modelName string, resp *genai.EmbedContentResponse) {
+	if m == nil {
+		return
+	}
+	u, ok := embeddingUsage(resp)
+	m.add(modelName, u)
+	if !ok {
+		m.mu.Lock()
+		m.totals[modelName].unreported = true
+		m.mu.Unlock()
+	}
+}
+
+const unreportedEmbeddingUsage = "token usage of embeddings isn't reported by the API"
+
+// usageGroups are the values of the --by flag of 'usage report', with the
+// function computing the group of a log entry's usage record.
+var usageGroups = map[string]func(r *promptlog.UsageRecord) string{
+	"day":     func(r *promptlog.UsageRecord) string { return r.Time.Local().Format(time.DateOnly) },
+	"month":   func(r *promptlog.UsageRecord) string { return r.Time.Local().Format("2006-01") },
+	"model":   func(r *promptlog.UsageRecord) string { return r.Model },
+	"command": func(r *promptlog.UsageRecord) string { return r.Command },
+}
+
+// usageRow is a row of the usage report: the total usage of the log entries
+// in a group.
+type usageRow struct {
+	Group          map[string]string `json:"group"`
+	Requests       int               `json:"requests"`
+	PromptTokens   int64             `json:"prompt_tokens"`
+	CachedTokens   int64             `json:"cached_tokens"`
+	ResponseTokens int64             `json:"response_tokens"`
+	CostUSD        float64           `json:"cost_usd"`
+
+	// UnpricedRequests is the number of requests to models without a price,
+	// which aren't included in CostUSD.
+	UnpricedRequests int `json:"unpriced_requests,omitempty"`
+}
+
+func runUsageReportCmd(cmd *cobra.Command, args []string) {
+	since, until := usageReportRange(cmd)
+	groups := mustGetStringSliceFlag(cmd, "by")
+	for _, g := range groups {
+		if usageGroups[g] == nil {
+			log.Fatalf("expect --by to be a list of day, month, model and command; got %q", g)
+		}
+	}
+	prices := loadPrices()
+
+	db := openLog()
+	defer db.Close()
+	entries, err := db.UsageBetween(since, until)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Rows are listed in the order of their first entry, which is
+	// chronological for days and months.
+	var rows []*usageRow
+	byKey := make(map[string]*usageRow)
+	total := &usageRow{Group: map[string]string{}}
+	unpriced := make(map[string]bool)
+	for _, e := range entries {
+		group := make(map[string]string)
+		var values []string
+		for _, g := range groups {
+			group[g] = usageGroups[g](e)
+			values = append(values, group[g])
+		}
+		key := strings.Join(values, "\x00")
+		row := byKey[key]
+		if row == nil {
+			row = &usageRow{Group: group}
+			byKey[key] = row
+			rows = append(rows, row)
+		}
+
+		u := pricing.Usage{
+			PromptTokens:   e.Usage.PromptTokens,
+			CachedTokens:   e.Usage.CachedTokens,
+			ResponseTokens: e.Usage.ResponseTokens,
+		}
+		price, ok := prices.Lookup(e.Model)
+		if !ok {
+			unpriced[e.Model] = true
+		}
+		for _, r := range []*usageRow{row, total} {
+			r.Requests++
+			r.PromptTokens += int64(u.PromptTokens)
+			r.CachedTokens += int64(u.CachedTokens)
+			r.ResponseTokens += int64(u.ResponseTokens)
+			if ok {
+				r.CostUSD += price.Cost(u)
+			} else {
+				r.UnpricedRequests++
+			}
+		}
+	}
+
+	if mustGetBoolFlag(cmd, "json") {
+		if rows == nil {
+			rows = []*usageRow{}
+		}
+		err := emitJSON(os.Stdout, struct {
+			Rows  []*usageRow `json:"rows"`
+			Total *usageRow   `json:"total"`
+		}{rows, total})
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		printUsageReport(os.Stdout, groups, rows, total)
+	}
+
+	if len(unpriced) > 0 {
+		fmt.Fprintf(os.Stderr, "warning: no price for %s; their requests aren't included in the costs (marked with *) (see 'gemini-cli help usage')\n",
+			strings.Join(slices.Sorted(maps.Keys(unpriced)), ", "))
+	}
+}
+
+// usageReportRange returns the time range of the log entries to report,
+// from the --since, --until and --month flags of cmd. Zero times leave the
+// range open.
+func usageReportRange(cmd *cobra.Command) (since, until time.Time) {
+	parseDate := func(flag, layout string) time.Time {
+		v := mustGetStringFlag(cmd, flag)
+		if v == "" {
+			return time.Time{}
+		}
+		t, err := time.ParseInLocation(layout, v, time.Local)
+		if err != nil {
+			log.Fatalf("expect --%s to be a date like %s, got %q", flag, layout, v)
+		}
+		return t
+	}
+
+	since = parseDate("since", time.DateOnly)
+	until = parseDate("until", time.DateOnly)
+	if month := parseDate("month", "2006-01"); !month.IsZero() {
+		if !since.IsZero() || !until.IsZero() {
+			log.Fatal("--month can't be used with --since or --until")
+		}
+		return month, month.AddDate(0, 1, 0)
+	}
+	if !since.IsZero() && !until.IsZero() && !since.Before(until) {
+		log.Fatal("expect --since to be before --until")
+	}
+	return since, until
+}
+
+// printUsageReport prints the rows of a usage report grouped by groups, and
+// their total, to w as a table.
+func printUsageReport(w io.Writer, groups []string, rows []*usageRow, total *usageRow) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	var header []string
+	for _, g := range groups {
+		header = append(header, strings.ToUpper(g))
+	}
+	header = append(header, "REQUESTS", "PROMPT", "CACHED", "RESPONSE", "COST")
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+
+	printRow := func(values []string, r *usageRow) {
+		cost := fmt.Sprintf("$%.4f", r.CostUSD)
+		if r.UnpricedRequests > 0 {
+			cost += "*"
+		}
+		values = append(values, fmt.Sprint(r.Requests), fmt.Sprint(r.PromptTokens), fmt.Sprint(r.CachedTokens),
+			fmt.Sprint(r.ResponseTokens), cost)
+		fmt.Fprintln(tw, strings.Join(values, "\t"))
+	}
+	for _, r := range rows {
+		var values []string
+		for _, g := range groups {
+			values = append(values, r.Group[g])
+		}
+		printRow(values, r)
+	}
+	totalValues := make([]string, len(groups))
+	if len(groups) > 0 {
+		totalValues[0] = "total"
+	}
+	printRow(totalValues, total)
+	tw.Flush()
+}
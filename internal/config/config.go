@@ -0,0 +1,119 @@
+// Package config loads the user configuration of gemini-cli.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/eliben/gemini-cli/internal/pricing"
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the configuration file inside [Dir].
+const FileName = "config.yaml"
+
+// Config is the user configuration of gemini-cli. It's loaded from a YAML
+// file and provides defaults for some of the command-line flags. Flags
+// provided explicitly on the command-line take precedence.
+//
+// An example configuration file:
+//
+//	safety:
+//	  harassment: medium
+//	  dangerous: none
+//	pricing:
+//	  gemini-1.5-flash:
+//	    input: 0.075
+//	    output: 0.30
+//	    cached_input: 0.01875
+type Config struct {
+	// Safety maps harm categories to block thresholds, with the same names
+	// accepted by the --safety flag.
+	Safety map[string]string `yaml:"safety"`
+
+	// Pricing overrides the prices of models in the built-in price table
+	// ([pricing.Default]), or adds prices for other models.
+	Pricing pricing.Table `yaml:"pricing"`
+}
+
+// Dir returns the directory holding the configuration of gemini-cli. It's
+// taken from the GEMINI_CLI_CONFIG_DIR environment variable if that's set,
+// and is otherwise the gemini-cli directory inside [os.UserConfigDir].
+func Dir() (string, error) {
+	if dir := os.Getenv("GEMINI_CLI_CONFIG_DIR"); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gemini-cli"), nil
+}
+
+// DataDir returns the directory holding the data gemini-cli keeps between
+// runs, like its log of prompts and responses. It's taken from the
+// GEMINI_CLI_DATA_DIR environment variable if that's set, and is otherwise
+// the gemini-cli directory inside the platform's user data directory
+// ($XDG_DATA_HOME or ~/.local/share on Unix systems).
+func DataDir() (string, error) {
+	if dir := os.Getenv("GEMINI_CLI_DATA_DIR"); dir != "" {
+		return dir, nil
+	}
+
+	var dir string
+	switch runtime.GOOS {
+	case "windows":
+		dir = os.Getenv("LocalAppData")
+		if dir == "" {
+			return "", errors.New("%LocalAppData% is not defined")
+		}
+	case "darwin", "ios":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, "Library", "Application Support")
+	default:
+		dir = os.Getenv("XDG_DATA_HOME")
+		if dir == "" || !filepath.IsAbs(dir) {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", err
+			}
+			dir = filepath.Join(home, ".local", "share")
+		}
+	}
+	return filepath.Join(dir, "gemini-cli"), nil
+}
+
+// Load loads the configuration file from [Dir]. If there's no configuration
+// file, an empty configuration is returned.
+func Load() (*Config, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	return LoadFile(filepath.Join(dir, FileName))
+}
+
+// LoadFile loads the configuration from the file at path. If the file doesn't
+// exist, an empty configuration is returned.
+func LoadFile(path string) (*Config, error) {
+	cfg := &Config{}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("parsing config file %v: %w", path, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eliben/gemini-cli/internal/pricing"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	err := os.WriteFile(path, []byte(`
+safety:
+  harassment: medium
+  dangerous: none
+pricing:
+  gemini-1.5-flash:
+    input: 0.1
+    output: 0.4
+  my-tuned-model:
+    input: 1
+    long_context_threshold: 1000
+    long_input: 2
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Config{
+		Safety: map[string]string{"harassment": "medium", "dangerous": "none"},
+		Pricing: pricing.Table{
+			"gemini-1.5-flash": {Input: 0.1, Output: 0.4},
+			"my-tuned-model":   {Input: 1, LongContextThreshold: 1000, LongInput: 2},
+		},
+	}
+	if diff := cmp.Diff(want, cfg); diff != "" {
+		t.Errorf("config mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLoadFileMissing(t *testing.T) {
+	cfg, err := LoadFile(filepath.Join(t.TempDir(), "nosuchfile.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(&Config{}, cfg); diff != "" {
+		t.Errorf("config mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLoadFileInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(path, []byte("safety: [1, 2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadFile(path); err == nil {
+		t.Error("expected error for invalid config file")
+	}
+}
+
+func TestDirFromEnv(t *testing.T) {
+	t.Setenv("GEMINI_CLI_CONFIG_DIR", "/some/dir")
+	dir, err := Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != "/some/dir" {
+		t.Errorf("got dir %q, want /some/dir", dir)
+	}
+}
+
+func TestDataDirFromEnv(t *testing.T) {
+	t.Setenv("GEMINI_CLI_DATA_DIR", "/some/data")
+	dir, err := DataDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != "/some/data" {
+		t.Errorf("got dir %q, want /some/data", dir)
+	}
+}
@@ -0,0 +1,221 @@
+// Package contextpack packs trees of text files into a single piece of text,
+// to provide them as context in prompts.
+//
+// Files are selected by directories or glob patterns, skipping files ignored
+// by .gitignore, binary files and files over size limits. In the packed
+// text, each file is wrapped in a <file path="..."> element, so models can
+// tell where files start and end, and which file is which.
+package contextpack
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/eliben/gemini-cli/internal/mediatype"
+)
+
+// Options limits the files packed.
+type Options struct {
+	// MaxFileSize is the maximal size of a single file, in bytes; larger
+	// files are skipped. Zero means no limit.
+	MaxFileSize int64
+
+	// MaxTotalSize is the maximal total size of all the packed files, in
+	// bytes; files that would exceed it are skipped. Zero means no limit.
+	MaxTotalSize int64
+}
+
+// File is a file included in a pack.
+type File struct {
+	Path string
+	Size int64
+}
+
+// Skipped is a file that was left out of a pack, and the reason why.
+type Skipped struct {
+	Path   string
+	Reason string
+}
+
+// Reasons files are skipped.
+const (
+	ReasonBinary        = "binary"
+	ReasonFileTooLarge  = "larger than the file size limit"
+	ReasonTotalTooLarge = "exceeds the total size limit"
+)
+
+// Pack is the result of packing files.
+type Pack struct {
+	// Text is the packed text of all the included files.
+	Text string
+
+	Files   []File
+	Skipped []Skipped
+}
+
+// Size returns the total size of the files in p.
+func (p *Pack) Size() int64 {
+	var n int64
+	for _, f := range p.Files {
+		n += f.Size
+	}
+	return n
+}
+
+// New packs the files selected by specs, in order. Each spec is either a
+// path (of a directory, for all the files in it recursively, or of a single
+// file) or a glob pattern of files, in which '**' matches any number of
+// directories (like 'internal/**/*.go'). Files ignored by .gitignore and
+// .git directories are skipped, except for files named explicitly.
+//
+// An error is returned if a spec doesn't match anything, or if files can't
+// be read.
+func New(specs []string, opts Options) (*Pack, error) {
+	p := &packer{opts: opts, seen: make(map[string]bool), pack: &Pack{}}
+	for _, spec := range specs {
+		if err := p.add(spec); err != nil {
+			return nil, err
+		}
+	}
+	p.pack.Text = p.sb.String()
+	return p.pack, nil
+}
+
+type packer struct {
+	opts  Options
+	seen  map[string]bool
+	total int64
+	sb    strings.Builder
+	pack  *Pack
+}
+
+// add packs the files selected by spec.
+func (p *packer) add(spec string) error {
+	root, pattern := splitPattern(spec)
+	if pattern == nil {
+		fi, err := os.Stat(spec)
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			return p.addFile(spec, fi.Size())
+		}
+		pattern = []string{"**"}
+	}
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return fmt.Errorf("no files match %q", spec)
+	}
+
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	ig := &ignorer{}
+	if err := ig.loadParents(absRoot); err != nil {
+		return err
+	}
+
+	matched := false
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		absPath := filepath.Join(absRoot, rel)
+		if d.IsDir() {
+			if path != root && (d.Name() == ".git" || ig.ignored(absPath, true)) {
+				return filepath.SkipDir
+			}
+			return ig.loadDir(absPath)
+		}
+		if !d.Type().IsRegular() || ig.ignored(absPath, false) {
+			return nil
+		}
+		if !matchSegments(pattern, strings.Split(filepath.ToSlash(rel), "/")) {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		matched = true
+		return p.addFile(path, fi.Size())
+	})
+	if err != nil {
+		return err
+	}
+	if !matched {
+		return fmt.Errorf("no files match %q", spec)
+	}
+	return nil
+}
+
+// addFile packs the file at path, if it's within the limits and it's text.
+func (p *packer) addFile(path string, size int64) error {
+	path = filepath.Clean(path)
+	if p.seen[path] {
+		return nil
+	}
+	p.seen[path] = true
+
+	if p.opts.MaxFileSize > 0 && size > p.opts.MaxFileSize {
+		p.skip(path, ReasonFileTooLarge)
+		return nil
+	}
+	if p.opts.MaxTotalSize > 0 && p.total+size > p.opts.MaxTotalSize {
+		p.skip(path, ReasonTotalTooLarge)
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if !mediatype.IsText(mediatype.Detect(path, data)) {
+		p.skip(path, ReasonBinary)
+		return nil
+	}
+
+	slashPath := filepath.ToSlash(path)
+	fmt.Fprintf(&p.sb, "<file path=%q>\n", slashPath)
+	text := mediatype.DecodeText(data)
+	p.sb.WriteString(text)
+	if len(text) > 0 && text[len(text)-1] != '\n' {
+		p.sb.WriteByte('\n')
+	}
+	p.sb.WriteString("</file>\n")
+
+	p.total += int64(len(data))
+	p.pack.Files = append(p.pack.Files, File{Path: slashPath, Size: int64(len(data))})
+	return nil
+}
+
+func (p *packer) skip(path string, reason string) {
+	p.pack.Skipped = append(p.pack.Skipped, Skipped{Path: filepath.ToSlash(path), Reason: reason})
+}
+
+// splitPattern splits spec into the directory to walk and the glob pattern
+// (as path segments) to match the paths of files under it with. If spec
+// isn't a pattern, it returns spec and a nil pattern.
+func splitPattern(spec string) (string, []string) {
+	segments := strings.Split(filepath.ToSlash(spec), "/")
+	for i, seg := range segments {
+		if strings.ContainsAny(seg, `*?[`) {
+			root := strings.Join(segments[:i], "/")
+			if root == "" {
+				root = "."
+				if i > 0 {
+					// The pattern is an absolute path.
+					root = "/"
+				}
+			}
+			return filepath.FromSlash(root), segments[i:]
+		}
+	}
+	return spec, nil
+}
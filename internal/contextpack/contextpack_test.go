@@ -0,0 +1,155 @@
+package contextpack
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// writeTree creates the files in tree (paths to contents) under dir.
+func writeTree(t *testing.T, dir string, tree map[string]string) {
+	t.Helper()
+	for path, content := range tree {
+		full := filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func paths(files []File) []string {
+	var ps []string
+	for _, f := range files {
+		ps = append(ps, f.Path)
+	}
+	return ps
+}
+
+func TestPack(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		".git/config":         "[core]",
+		".gitignore":          "*.log\n/build/\n!keep.log\n",
+		"main.go":             "package main\n",
+		"lib/lib.go":          "package lib",
+		"lib/lib_test.go":     "package lib\n",
+		"lib/.gitignore":      "generated.go\n",
+		"lib/generated.go":    "package lib\n",
+		"lib/sub/sub.go":      "package sub\n",
+		"lib/sub/notes.txt":   "notes\n",
+		"build/out.go":        "package out\n",
+		"debug.log":           "log\n",
+		"keep.log":            "kept\n",
+		"image.png":           "\x89PNG\x0D\x0A\x1A\x0A\x00\x00",
+		"docs/build/index.md": "# not ignored: /build/ is anchored\n",
+	})
+	chdir(t, dir)
+
+	var tests = []struct {
+		specs []string
+		want  []string
+	}{
+		{[]string{"."}, []string{
+			".gitignore", "docs/build/index.md", "keep.log", "lib/.gitignore", "lib/lib.go",
+			"lib/lib_test.go", "lib/sub/notes.txt", "lib/sub/sub.go", "main.go",
+		}},
+		{[]string{"lib"}, []string{"lib/.gitignore", "lib/lib.go", "lib/lib_test.go", "lib/sub/notes.txt", "lib/sub/sub.go"}},
+		{[]string{"lib/*.go"}, []string{"lib/lib.go", "lib/lib_test.go"}},
+		{[]string{"**/*.go"}, []string{"lib/lib.go", "lib/lib_test.go", "lib/sub/sub.go", "main.go"}},
+		{[]string{"lib/sub"}, []string{"lib/sub/notes.txt", "lib/sub/sub.go"}},
+
+		// Files named explicitly are included even if they're ignored, and
+		// files are included once.
+		{[]string{"debug.log", "main.go", "*.go"}, []string{"debug.log", "main.go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.specs, ","), func(t *testing.T) {
+			p, err := New(tt.specs, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, paths(p.Files)); diff != "" {
+				t.Errorf("files mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPackText(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.txt":     "first",
+		"b/b.txt":   "second\n",
+		"image.png": "\x89PNG\x0D\x0A\x1A\x0A\x00\x00",
+	})
+	chdir(t, dir)
+
+	p, err := New([]string{"."}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "<file path=\"a.txt\">\nfirst\n</file>\n<file path=\"b/b.txt\">\nsecond\n</file>\n"
+	if p.Text != want {
+		t.Errorf("got text %q, want %q", p.Text, want)
+	}
+	if diff := cmp.Diff([]Skipped{{"image.png", ReasonBinary}}, p.Skipped); diff != "" {
+		t.Errorf("skipped mismatch (-want +got):\n%s", diff)
+	}
+	if p.Size() != 12 {
+		t.Errorf("got size %d, want 12", p.Size())
+	}
+}
+
+func TestPackLimits(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.txt": strings.Repeat("a", 10),
+		"b.txt": strings.Repeat("b", 100),
+		"c.txt": strings.Repeat("c", 10),
+		"d.txt": strings.Repeat("d", 10),
+	})
+	chdir(t, dir)
+
+	p, err := New([]string{"."}, Options{MaxFileSize: 50, MaxTotalSize: 25})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"a.txt", "c.txt"}, paths(p.Files)); diff != "" {
+		t.Errorf("files mismatch (-want +got):\n%s", diff)
+	}
+	wantSkipped := []Skipped{{"b.txt", ReasonFileTooLarge}, {"d.txt", ReasonTotalTooLarge}}
+	if diff := cmp.Diff(wantSkipped, p.Skipped); diff != "" {
+		t.Errorf("skipped mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestPackErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a.txt": "a"})
+	chdir(t, dir)
+
+	for _, spec := range []string{"missing", "*.go", "missing/**"} {
+		if _, err := New([]string{spec}, Options{}); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
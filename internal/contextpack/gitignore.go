@@ -0,0 +1,135 @@
+package contextpack
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRule is a single pattern from a .gitignore file.
+type ignoreRule struct {
+	// base is the absolute path of the directory the .gitignore file is in;
+	// patterns are matched against paths relative to it.
+	base    string
+	pattern []string
+	negate  bool
+	dirOnly bool
+}
+
+// ignorer decides which paths are ignored, following the rules of the
+// .gitignore files seen so far. It implements the commonly used parts of
+// the gitignore format: comments, negation with '!', directory-only
+// patterns with a trailing '/', patterns anchored by a '/', and '**'.
+type ignorer struct {
+	rules []ignoreRule
+}
+
+// loadDir adds the rules of the .gitignore file in dir, if there is one. dir
+// must be an absolute path.
+func (ig *ignorer) loadDir(dir string) error {
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{base: dir}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, `\`)
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		// Patterns without a slash (other than a trailing one) match at any
+		// depth; other patterns are relative to the .gitignore's directory.
+		if !strings.Contains(line, "/") {
+			rule.pattern = []string{"**", line}
+		} else {
+			rule.pattern = strings.Split(strings.TrimPrefix(line, "/"), "/")
+		}
+		ig.rules = append(ig.rules, rule)
+	}
+	return scanner.Err()
+}
+
+// loadParents adds the rules of the .gitignore files in the parent
+// directories of dir (an absolute path), up to the root of the git
+// repository dir is in. If dir isn't in a git repository, nothing is added.
+func (ig *ignorer) loadParents(dir string) error {
+	var parents []string
+	for d := filepath.Dir(dir); ; d = filepath.Dir(d) {
+		parents = append(parents, d)
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			break
+		}
+		if d == filepath.Dir(d) {
+			// Reached the file system root without finding a repository.
+			return nil
+		}
+	}
+	for i := len(parents) - 1; i >= 0; i-- {
+		if err := ig.loadDir(parents[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ignored reports whether the file or directory at the absolute path p is
+// ignored. The last matching rule wins, so later rules (and rules of deeper
+// .gitignore files) can negate earlier ones.
+func (ig *ignorer) ignored(p string, isDir bool) bool {
+	ignored := false
+	for _, rule := range ig.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		rel, err := filepath.Rel(rule.base, p)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if matchSegments(rule.pattern, strings.Split(filepath.ToSlash(rel), "/")) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// matchSegments reports whether the path segments name match the pattern
+// segments pat. Each pattern segment is matched with path.Match, except
+// "**", which matches any number of segments.
+func matchSegments(pat, name []string) bool {
+	if len(pat) == 0 {
+		return len(name) == 0
+	}
+	if pat[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pat[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	ok, _ := path.Match(pat[0], name[0])
+	return ok && matchSegments(pat[1:], name[1:])
+}
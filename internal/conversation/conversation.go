@@ -0,0 +1,128 @@
+// Package conversation loads conversations from YAML files: ordered lists of
+// turns of a user and a model, used as the history of a prompt (for example,
+// for few-shot prompting with examples of requests and responses).
+//
+// Every turn has a role (user or model) and either a single text, or a list
+// of parts, each of them a text, a file or a URL:
+//
+//	# conversation.yaml
+//	- role: user
+//	  text: Extract the colors mentioned in "a red fox and a grey wolf".
+//	- role: model
+//	  text: '["red", "grey"]'
+//	- role: user
+//	  parts:
+//	    - text: Extract the colors in this image.
+//	    - file: images/sunset.jpg
+//	- role: model
+//	  text: '["orange", "purple"]'
+//
+// Relative file paths are relative to the directory of the conversation file.
+package conversation
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Roles of turns.
+const (
+	RoleUser  = "user"
+	RoleModel = "model"
+)
+
+// Turn is a turn of a conversation.
+type Turn struct {
+	Role  string `yaml:"role"`
+	Text  string `yaml:"text,omitempty"`
+	Parts []Part `yaml:"parts,omitempty"`
+}
+
+// Part is a part of a turn; exactly one of its fields is set.
+type Part struct {
+	Text string `yaml:"text,omitempty"`
+	File string `yaml:"file,omitempty"`
+	URL  string `yaml:"url,omitempty"`
+}
+
+// Load loads the conversation in the file at path. The paths of files in the
+// returned turns are relative to the current directory.
+func Load(path string) ([]Turn, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	turns, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("conversation %s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	for i := range turns {
+		for j, p := range turns[i].Parts {
+			if p.File != "" && !filepath.IsAbs(p.File) {
+				turns[i].Parts[j].File = filepath.Join(dir, p.File)
+			}
+		}
+	}
+	return turns, nil
+}
+
+// Parse parses a conversation from its YAML representation, and checks that
+// its turns are valid. In the returned turns, the text of turns is moved to
+// their parts, so Text is always empty.
+func Parse(data []byte) ([]Turn, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var turns []Turn
+	if err := dec.Decode(&turns); err != nil {
+		return nil, err
+	}
+	if len(turns) == 0 {
+		return nil, errors.New("expect at least one turn")
+	}
+
+	for i := range turns {
+		t := &turns[i]
+		if t.Role != RoleUser && t.Role != RoleModel {
+			return nil, fmt.Errorf("turn %d: expect role to be %s or %s, got %q", i+1, RoleUser, RoleModel, t.Role)
+		}
+		switch {
+		case t.Text != "" && len(t.Parts) > 0:
+			return nil, fmt.Errorf("turn %d: expect either text or parts, not both", i+1)
+		case t.Text != "":
+			t.Parts = []Part{{Text: t.Text}}
+			t.Text = ""
+		case len(t.Parts) == 0:
+			return nil, fmt.Errorf("turn %d: expect text or parts", i+1)
+		}
+		for j, p := range t.Parts {
+			if err := p.check(); err != nil {
+				return nil, fmt.Errorf("turn %d, part %d: %w", i+1, j+1, err)
+			}
+		}
+	}
+	return turns, nil
+}
+
+func (p Part) check() error {
+	n := 0
+	for _, field := range []string{p.Text, p.File, p.URL} {
+		if field != "" {
+			n++
+		}
+	}
+	if n != 1 {
+		return errors.New("expect exactly one of text, file or url")
+	}
+	if p.URL != "" && !strings.HasPrefix(p.URL, "http://") && !strings.HasPrefix(p.URL, "https://") {
+		return fmt.Errorf("expect an http or https URL, got %q", p.URL)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package conversation
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	data := `
+- role: user
+  text: hello
+- role: model
+  text: hi there
+- role: user
+  parts:
+    - text: what's this?
+    - file: cat.png
+    - url: https://example.com/cat
+`
+	turns, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Turn{
+		{Role: RoleUser, Parts: []Part{{Text: "hello"}}},
+		{Role: RoleModel, Parts: []Part{{Text: "hi there"}}},
+		{Role: RoleUser, Parts: []Part{{Text: "what's this?"}, {File: "cat.png"}, {URL: "https://example.com/cat"}}},
+	}
+	if !reflect.DeepEqual(turns, want) {
+		t.Errorf("got %+v, want %+v", turns, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	var tests = []struct {
+		data    string
+		wantErr string
+	}{
+		{"[]", "expect at least one turn"},
+		{"- role: assistant\n  text: hi", `turn 1: expect role to be user or model, got "assistant"`},
+		{"- role: user", "turn 1: expect text or parts"},
+		{"- role: user\n  text: a\n  parts: [{text: b}]", "turn 1: expect either text or parts"},
+		{"- role: user\n  parts: [{text: a, file: b}]", "turn 1, part 1: expect exactly one of text, file or url"},
+		{"- role: user\n  parts: [{url: ftp://x}]", "expect an http or https URL"},
+		{"- role: user\n  content: hi", "field content not found"},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse([]byte(tt.data))
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("Parse(%q): got error %v, want %q", tt.data, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "convo.yaml")
+	data := "- role: user\n  parts:\n    - file: img/cat.png\n    - file: /abs/dog.png\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	turns, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Part{{File: filepath.Join(dir, "img/cat.png")}, {File: "/abs/dog.png"}}
+	if !reflect.DeepEqual(turns[0].Parts, want) {
+		t.Errorf("got parts %+v, want %+v", turns[0].Parts, want)
+	}
+
+	if _, err := Load(filepath.Join(dir, "nope.yaml")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
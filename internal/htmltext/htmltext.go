@@ -0,0 +1,224 @@
+// Package htmltext converts HTML documents to readable plain text, to send
+// the contents of web pages to models.
+//
+// The conversion keeps the document's text and a light markdown-like
+// structure (headings, list items, links and preformatted blocks), and drops
+// everything that isn't content: scripts, styles, forms, embedded media and
+// so on.
+package htmltext
+
+import (
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// skipped are elements whose contents are dropped.
+var skipped = map[atom.Atom]bool{
+	atom.Head:     true,
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Svg:      true,
+	atom.Iframe:   true,
+	atom.Object:   true,
+	atom.Canvas:   true,
+	atom.Form:     true,
+	atom.Button:   true,
+	atom.Select:   true,
+}
+
+// blocks are elements that start a new paragraph.
+var blocks = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true,
+	atom.Dd: true, atom.Details: true, atom.Div: true, atom.Dl: true, atom.Dt: true,
+	atom.Fieldset: true, atom.Figcaption: true, atom.Figure: true, atom.Footer: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Header: true, atom.Hr: true, atom.Li: true, atom.Main: true, atom.Nav: true,
+	atom.Ol: true, atom.P: true, atom.Pre: true, atom.Section: true, atom.Summary: true,
+	atom.Table: true, atom.Tr: true, atom.Ul: true,
+}
+
+var headingLevels = map[atom.Atom]int{
+	atom.H1: 1, atom.H2: 2, atom.H3: 3, atom.H4: 4, atom.H5: 5, atom.H6: 6,
+}
+
+// Convert reads an HTML document from r, and returns its text. If the
+// document has a title, it's the first line of the text.
+func Convert(r io.Reader) (string, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", err
+	}
+
+	c := &converter{}
+	if title := findTitle(doc); title != "" {
+		c.text("# " + title)
+		c.paragraph()
+	}
+	c.walk(doc)
+	return c.String(), nil
+}
+
+// converter accumulates the text of a document as it's walked.
+type converter struct {
+	sb strings.Builder
+
+	// pendingBreak is the line break waiting to be written before the next
+	// text: "" (none), "\n" or "\n\n".
+	pendingBreak string
+
+	// space is set when whitespace should separate the next text from the
+	// text before it.
+	space bool
+
+	inPre bool
+}
+
+var spaceRe = regexp.MustCompile(`\s+`)
+
+func (c *converter) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		if c.inPre {
+			c.raw(n.Data)
+			return
+		}
+		data := spaceRe.ReplaceAllString(n.Data, " ")
+		if strings.HasPrefix(data, " ") {
+			c.space = true
+		}
+		if data = strings.TrimSpace(data); data != "" {
+			c.text(data)
+			c.space = strings.HasSuffix(n.Data, " ") || strings.HasSuffix(n.Data, "\n") || strings.HasSuffix(n.Data, "\t")
+		}
+		return
+	case html.ElementNode:
+		if skipped[n.DataAtom] {
+			return
+		}
+	case html.DocumentNode:
+	default:
+		return
+	}
+
+	a := n.DataAtom
+	if blocks[a] {
+		c.paragraph()
+	}
+	switch {
+	case a == atom.Br:
+		c.line()
+	case headingLevels[a] > 0:
+		c.text(strings.Repeat("#", headingLevels[a]))
+		c.space = true
+	case a == atom.Li:
+		c.text("-")
+		c.space = true
+	case a == atom.Td || a == atom.Th:
+		c.text("|")
+		c.space = true
+	case a == atom.Pre:
+		c.text("```")
+		c.line()
+		c.inPre = true
+	case a == atom.Img:
+		if alt := attr(n, "alt"); alt != "" {
+			c.text("[image: " + alt + "]")
+		}
+	}
+
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		c.walk(child)
+	}
+
+	switch {
+	case a == atom.A:
+		if href := attr(n, "href"); strings.HasPrefix(href, "http://") || strings.HasPrefix(href, "https://") {
+			c.space = true
+			c.text("(" + href + ")")
+		}
+	case a == atom.Td || a == atom.Th:
+		c.space = true
+	case a == atom.Pre:
+		c.inPre = false
+		c.line()
+		c.text("```")
+	}
+	if blocks[a] {
+		c.paragraph()
+	}
+}
+
+// text writes s, preceded by any pending break or separating space.
+func (c *converter) text(s string) {
+	if c.sb.Len() > 0 {
+		if c.pendingBreak != "" {
+			c.sb.WriteString(c.pendingBreak)
+		} else if c.space {
+			c.sb.WriteByte(' ')
+		}
+	}
+	c.pendingBreak = ""
+	c.space = false
+	c.sb.WriteString(s)
+}
+
+// raw writes s as is, for preformatted text.
+func (c *converter) raw(s string) {
+	if c.pendingBreak != "" && c.sb.Len() > 0 {
+		c.sb.WriteString(c.pendingBreak)
+	}
+	c.pendingBreak = ""
+	c.space = false
+	c.sb.WriteString(s)
+}
+
+// line ends the current line.
+func (c *converter) line() {
+	if c.pendingBreak == "" {
+		c.pendingBreak = "\n"
+	}
+}
+
+// paragraph ends the current paragraph.
+func (c *converter) paragraph() {
+	c.pendingBreak = "\n\n"
+}
+
+func (c *converter) String() string {
+	return c.sb.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// findTitle returns the text of the document's <title>, or "" if it has
+// none.
+func findTitle(n *html.Node) string {
+	if n.Type == html.ElementNode && n.DataAtom == atom.Title {
+		var sb strings.Builder
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type == html.TextNode {
+				sb.WriteString(child.Data)
+			}
+		}
+		return strings.TrimSpace(spaceRe.ReplaceAllString(sb.String(), " "))
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if title := findTitle(child); title != "" {
+			return title
+		}
+	}
+	return ""
+}
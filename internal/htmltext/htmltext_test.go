@@ -0,0 +1,47 @@
+package htmltext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	var tests = []struct {
+		name string
+		html string
+		want string
+	}{
+		{"empty", "", ""},
+		{"plain", "<p>Hello, world</p>", "Hello, world"},
+		{"title",
+			"<html><head><title> My  page </title></head><body><p>Body</p></body></html>",
+			"# My page\n\nBody"},
+		{"whitespace", "<p>  lots\n\n of   <b>space</b>  here </p>", "lots of space here"},
+		{"inline", "<p>a<em>b</em> c</p>", "ab c"},
+		{"paragraphs", "<p>one</p><p>two</p><div>three</div>", "one\n\ntwo\n\nthree"},
+		{"br", "<p>line 1<br>line 2</p>", "line 1\nline 2"},
+		{"headings", "<h1>Top</h1><p>text</p><h3>Sub</h3>", "# Top\n\ntext\n\n### Sub"},
+		{"list", "<ul><li>first</li><li>second</li></ul>", "- first\n\n- second"},
+		{"link",
+			`<p>see <a href="https://go.dev">Go</a> and <a href="/rel">here</a></p>`,
+			"see Go (https://go.dev) and here"},
+		{"pre", "<p>code:</p><pre>x := 1\n  y := 2</pre>", "code:\n\n```\nx := 1\n  y := 2\n```"},
+		{"skipped",
+			"<script>var x = 1;</script><style>p {}</style><p>kept</p><noscript>no</noscript><svg><text>s</text></svg>",
+			"kept"},
+		{"img", `<p><img src="a.png" alt="a cat"> sits</p>`, "[image: a cat] sits"},
+		{"table", "<table><tr><th>a</th><th>b</th></tr><tr><td>1</td><td>2</td></tr></table>", "| a | b\n\n| 1 | 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Convert(strings.NewReader(tt.html))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
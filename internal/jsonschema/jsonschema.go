@@ -0,0 +1,347 @@
+// Package jsonschema implements the subset of JSON Schema that can be
+// expressed as a Gemini response schema: parsing schemas and validating JSON
+// values against them.
+//
+// The supported keywords are: type (a single type name or a list of types
+// that may include "null"), description, format, enum, properties, required,
+// additionalProperties (only as a boolean), items, minimum, maximum,
+// minItems and maxItems. Other keywords are ignored, except for schema
+// composition keywords like $ref or anyOf, which are reported as errors.
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+)
+
+// Type is a JSON Schema type name: "object", "array", "string", "number",
+// "integer" or "boolean".
+type Type string
+
+const (
+	TypeObject  Type = "object"
+	TypeArray   Type = "array"
+	TypeString  Type = "string"
+	TypeNumber  Type = "number"
+	TypeInteger Type = "integer"
+	TypeBoolean Type = "boolean"
+)
+
+// Schema is a parsed JSON Schema.
+type Schema struct {
+	Type        Type
+	Nullable    bool
+	Description string
+	Format      string
+
+	// Enum lists the allowed values; it's nil if any value is allowed.
+	Enum []any
+
+	// Properties and Required are the properties of an object. If
+	// AdditionalProperties is false, properties not listed in Properties
+	// aren't allowed.
+	Properties           map[string]*Schema
+	Required             []string
+	AdditionalProperties bool
+
+	// Items is the schema of array elements, and MinItems/MaxItems limit the
+	// length of arrays (when non-nil).
+	Items    *Schema
+	MinItems *int
+	MaxItems *int
+
+	// Minimum and Maximum limit numeric values (when non-nil).
+	Minimum *float64
+	Maximum *float64
+}
+
+// rawSchema is the JSON representation of a schema, as it's decoded.
+type rawSchema struct {
+	Type                 json.RawMessage       `json:"type"`
+	Description          string                `json:"description"`
+	Format               string                `json:"format"`
+	Nullable             bool                  `json:"nullable"`
+	Enum                 []any                 `json:"enum"`
+	Properties           map[string]*rawSchema `json:"properties"`
+	Required             []string              `json:"required"`
+	AdditionalProperties *bool                 `json:"additionalProperties"`
+	Items                *rawSchema            `json:"items"`
+	MinItems             *int                  `json:"minItems"`
+	MaxItems             *int                  `json:"maxItems"`
+	Minimum              *float64              `json:"minimum"`
+	Maximum              *float64              `json:"maximum"`
+
+	Ref   json.RawMessage `json:"$ref"`
+	AnyOf json.RawMessage `json:"anyOf"`
+	OneOf json.RawMessage `json:"oneOf"`
+	AllOf json.RawMessage `json:"allOf"`
+	Not   json.RawMessage `json:"not"`
+}
+
+// Parse parses a JSON Schema from its JSON representation.
+func Parse(data []byte) (*Schema, error) {
+	var raw rawSchema
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("decoding schema: %w", err)
+	}
+	return fromRaw(&raw, "$")
+}
+
+func fromRaw(raw *rawSchema, path string) (*Schema, error) {
+	unsupported := []struct {
+		keyword string
+		value   json.RawMessage
+	}{
+		{"$ref", raw.Ref}, {"anyOf", raw.AnyOf}, {"oneOf", raw.OneOf}, {"allOf", raw.AllOf}, {"not", raw.Not},
+	}
+	for _, u := range unsupported {
+		if u.value != nil {
+			return nil, fmt.Errorf("%s: unsupported schema keyword %q", path, u.keyword)
+		}
+	}
+
+	s := &Schema{
+		Description:          raw.Description,
+		Format:               raw.Format,
+		Nullable:             raw.Nullable,
+		Enum:                 raw.Enum,
+		Required:             raw.Required,
+		AdditionalProperties: raw.AdditionalProperties == nil || *raw.AdditionalProperties,
+		MinItems:             raw.MinItems,
+		MaxItems:             raw.MaxItems,
+		Minimum:              raw.Minimum,
+		Maximum:              raw.Maximum,
+	}
+
+	if err := s.parseType(raw.Type, path); err != nil {
+		return nil, err
+	}
+
+	if len(raw.Properties) > 0 {
+		s.Properties = make(map[string]*Schema)
+		for _, name := range slices.Sorted(maps.Keys(raw.Properties)) {
+			ps, err := fromRaw(raw.Properties[name], path+"."+name)
+			if err != nil {
+				return nil, err
+			}
+			s.Properties[name] = ps
+		}
+	}
+	for _, name := range s.Required {
+		if _, ok := s.Properties[name]; !ok {
+			return nil, fmt.Errorf("%s: required property %q isn't listed in properties", path, name)
+		}
+	}
+
+	if raw.Items != nil {
+		items, err := fromRaw(raw.Items, path+"[]")
+		if err != nil {
+			return nil, err
+		}
+		s.Items = items
+	}
+	return s, nil
+}
+
+// parseType parses the value of the "type" keyword into s, which is either
+// a single type name or a list of type names. Lists may have a single type
+// in addition to "null".
+func (s *Schema) parseType(rawType json.RawMessage, path string) error {
+	if rawType == nil {
+		return fmt.Errorf("%s: schema has no type", path)
+	}
+
+	var names []string
+	var name string
+	if err := json.Unmarshal(rawType, &name); err == nil {
+		names = []string{name}
+	} else if err := json.Unmarshal(rawType, &names); err != nil {
+		return fmt.Errorf("%s: expect string or list of strings for type", path)
+	}
+
+	for _, name := range names {
+		switch t := Type(name); t {
+		case TypeObject, TypeArray, TypeString, TypeNumber, TypeInteger, TypeBoolean:
+			if s.Type != "" {
+				return fmt.Errorf("%s: only a single non-null type is supported, got %s", path, rawType)
+			}
+			s.Type = t
+		case "null":
+			s.Nullable = true
+		default:
+			return fmt.Errorf("%s: unknown type %q", path, name)
+		}
+	}
+	if s.Type == "" {
+		return fmt.Errorf("%s: schema has no non-null type", path)
+	}
+	return nil
+}
+
+// ValidateJSON decodes data as JSON and validates it against s.
+func (s *Schema) ValidateJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	if dec.More() {
+		return fmt.Errorf("invalid JSON: unexpected data after top-level value")
+	}
+	return s.Validate(v)
+}
+
+// Validate validates a decoded JSON value against s. Numbers in v may be
+// either float64 or json.Number. The returned error describes the first
+// mismatch found, with a path to the offending value.
+func (s *Schema) Validate(v any) error {
+	return s.validate(v, "$")
+}
+
+func (s *Schema) validate(v any, path string) error {
+	if v == nil {
+		if s.Nullable {
+			return nil
+		}
+		return fmt.Errorf("%s: expected %s, got null", path, s.Type)
+	}
+
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e any) bool { return jsonEqual(e, v) }) {
+		return fmt.Errorf("%s: value %v is not one of the allowed values %v", path, v, s.Enum)
+	}
+
+	switch s.Type {
+	case TypeObject:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return typeMismatch(path, s.Type, v)
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(obj)) {
+			ps, ok := s.Properties[name]
+			if !ok {
+				if !s.AdditionalProperties {
+					return fmt.Errorf("%s: unexpected property %q", path, name)
+				}
+				continue
+			}
+			if err := ps.validate(obj[name], path+"."+name); err != nil {
+				return err
+			}
+		}
+	case TypeArray:
+		arr, ok := v.([]any)
+		if !ok {
+			return typeMismatch(path, s.Type, v)
+		}
+		if s.MinItems != nil && len(arr) < *s.MinItems {
+			return fmt.Errorf("%s: expected at least %d items, got %d", path, *s.MinItems, len(arr))
+		}
+		if s.MaxItems != nil && len(arr) > *s.MaxItems {
+			return fmt.Errorf("%s: expected at most %d items, got %d", path, *s.MaxItems, len(arr))
+		}
+		if s.Items != nil {
+			for i, item := range arr {
+				if err := s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case TypeString:
+		if _, ok := v.(string); !ok {
+			return typeMismatch(path, s.Type, v)
+		}
+	case TypeBoolean:
+		if _, ok := v.(bool); !ok {
+			return typeMismatch(path, s.Type, v)
+		}
+	case TypeNumber, TypeInteger:
+		f, ok := toFloat(v)
+		if !ok {
+			return typeMismatch(path, s.Type, v)
+		}
+		if s.Type == TypeInteger && f != math.Trunc(f) {
+			return fmt.Errorf("%s: expected integer, got %v", path, v)
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			return fmt.Errorf("%s: value %v is less than minimum %v", path, v, *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			return fmt.Errorf("%s: value %v is greater than maximum %v", path, v, *s.Maximum)
+		}
+	}
+	return nil
+}
+
+func typeMismatch(path string, want Type, v any) error {
+	return fmt.Errorf("%s: expected %s, got %s", path, want, jsonTypeName(v))
+}
+
+// jsonTypeName returns the JSON type name of a decoded JSON value.
+func jsonTypeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64, json.Number:
+		return "number"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// jsonEqual reports whether two decoded JSON values are equal; numbers are
+// compared by value regardless of their representation.
+func jsonEqual(a, b any) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	switch av := a.(type) {
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, v := range av {
+			if !jsonEqual(v, bv[k]) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		bv, ok := b.([]any)
+		return ok && slices.EqualFunc(av, bv, jsonEqual)
+	default:
+		return a == b
+	}
+}
@@ -0,0 +1,109 @@
+package jsonschema
+
+import (
+	"strings"
+	"testing"
+)
+
+var personSchema = `{
+  "type": "object",
+  "properties": {
+    "name": {"type": "string", "description": "full name"},
+    "age": {"type": "integer", "minimum": 0},
+    "email": {"type": ["string", "null"]},
+    "role": {"type": "string", "enum": ["admin", "user"]},
+    "tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2}
+  },
+  "required": ["name", "age"],
+  "additionalProperties": false
+}`
+
+func TestParse(t *testing.T) {
+	s, err := Parse([]byte(personSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Type != TypeObject || s.AdditionalProperties {
+		t.Errorf("got Type=%v AdditionalProperties=%v", s.Type, s.AdditionalProperties)
+	}
+	if len(s.Properties) != 5 {
+		t.Errorf("got %d properties, want 5", len(s.Properties))
+	}
+	if email := s.Properties["email"]; email.Type != TypeString || !email.Nullable {
+		t.Errorf("got email Type=%v Nullable=%v", email.Type, email.Nullable)
+	}
+	if tags := s.Properties["tags"]; tags.Items == nil || tags.Items.Type != TypeString || *tags.MaxItems != 2 {
+		t.Errorf("unexpected tags schema %+v", tags)
+	}
+	if desc := s.Properties["name"].Description; desc != "full name" {
+		t.Errorf("got description %q", desc)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	var tests = []struct {
+		schema  string
+		wantErr string
+	}{
+		{`{"type": "object", "properties": {"a": {"$ref": "#/x"}}}`, `$.a: unsupported schema keyword "$ref"`},
+		{`{"anyOf": [{"type": "string"}]}`, `unsupported schema keyword "anyOf"`},
+		{`{"description": "x"}`, `no type`},
+		{`{"type": "float"}`, `unknown type "float"`},
+		{`{"type": ["string", "integer"]}`, `only a single non-null type`},
+		{`{"type": ["null"]}`, `no non-null type`},
+		{`{"type": "object", "required": ["a"]}`, `isn't listed in properties`},
+		{`{"type": "array", "items": {"type": 5}}`, `$[]: expect string or list`},
+		{`{"type": `, `decoding schema`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.schema, func(t *testing.T) {
+			_, err := Parse([]byte(tt.schema))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateJSON(t *testing.T) {
+	s, err := Parse([]byte(personSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		data    string
+		wantErr string
+	}{
+		{`{"name": "joe", "age": 29}`, ""},
+		{`{"name": "joe", "age": 29.0, "email": null, "role": "admin", "tags": ["a", "b"]}`, ""},
+		{`{"name": "joe", "age": 29, "email": "joe@example.com"}`, ""},
+		{`{"name": "joe"}`, `$: missing required property "age"`},
+		{`{"name": "joe", "age": 29.5}`, `$.age: expected integer`},
+		{`{"name": "joe", "age": -1}`, `$.age: value -1 is less than minimum 0`},
+		{`{"name": 12, "age": 29}`, `$.name: expected string, got number`},
+		{`{"name": null, "age": 29}`, `$.name: expected string, got null`},
+		{`{"name": "joe", "age": 29, "role": "boss"}`, `$.role: value boss is not one of the allowed values`},
+		{`{"name": "joe", "age": 29, "tags": ["a", 1]}`, `$.tags[1]: expected string, got number`},
+		{`{"name": "joe", "age": 29, "tags": ["a", "b", "c"]}`, `$.tags: expected at most 2 items, got 3`},
+		{`{"name": "joe", "age": 29, "extra": true}`, `$: unexpected property "extra"`},
+		{`["joe"]`, `$: expected object, got array`},
+		{`{"name": "joe", "age": 29} {}`, `unexpected data after top-level value`},
+		{`{"name": "joe", `, `invalid JSON`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			err := s.ValidateJSON([]byte(tt.data))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("got error %v, want no error", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
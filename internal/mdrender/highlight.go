@@ -0,0 +1,155 @@
+package mdrender
+
+import (
+	"strings"
+	"unicode"
+)
+
+// syntax describes the lexical elements of a language, for highlighting.
+type syntax struct {
+	keywords       map[string]bool
+	lineComments   []string
+	backtickString bool
+}
+
+func words(s string) map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+var (
+	goSyntax = &syntax{
+		keywords: words(`break case chan const continue default defer else fallthrough for func go goto if
+			import interface map package range return select struct switch type var
+			true false nil iota any error string int int8 int16 int32 int64 uint uint8 uint16 uint32
+			uint64 uintptr byte rune float32 float64 bool append cap len make new panic recover`),
+		lineComments:   []string{"//"},
+		backtickString: true,
+	}
+	pythonSyntax = &syntax{
+		keywords: words(`and as assert async await break class continue def del elif else except finally
+			for from global if import in is lambda nonlocal not or pass raise return try while with
+			yield None True False self print len range`),
+		lineComments: []string{"#"},
+	}
+	jsSyntax = &syntax{
+		keywords: words(`async await break case catch class const continue debugger default delete do
+			else export extends finally for from function if import in instanceof let new of return
+			super switch this throw try typeof var void while with yield true false null undefined
+			interface type enum implements private public protected readonly`),
+		lineComments:   []string{"//"},
+		backtickString: true,
+	}
+	shellSyntax = &syntax{
+		keywords: words(`if then else elif fi for while until do done case esac in function return
+			exit export local echo cd set unset`),
+		lineComments: []string{"#"},
+	}
+	cSyntax = &syntax{
+		keywords: words(`auto break case char class const continue default delete do double else enum
+			extern final float for fn goto if impl import int let long match mod mut namespace new
+			package private protected public return self short signed sizeof static struct super
+			switch template this throw trait true false try typedef union unsigned use using virtual
+			void volatile where while null nullptr bool boolean String`),
+		lineComments: []string{"//"},
+	}
+	genericSyntax = &syntax{}
+)
+
+var syntaxes = map[string]*syntax{
+	"go":         goSyntax,
+	"golang":     goSyntax,
+	"python":     pythonSyntax,
+	"py":         pythonSyntax,
+	"javascript": jsSyntax,
+	"js":         jsSyntax,
+	"typescript": jsSyntax,
+	"ts":         jsSyntax,
+	"jsx":        jsSyntax,
+	"tsx":        jsSyntax,
+	"sh":         shellSyntax,
+	"bash":       shellSyntax,
+	"shell":      shellSyntax,
+	"zsh":        shellSyntax,
+	"c":          cSyntax,
+	"cpp":        cSyntax,
+	"c++":        cSyntax,
+	"java":       cSyntax,
+	"rust":       cSyntax,
+	"rs":         cSyntax,
+	"cs":         cSyntax,
+	"csharp":     cSyntax,
+	"kotlin":     cSyntax,
+	"swift":      cSyntax,
+	"yaml":       {lineComments: []string{"#"}},
+	"yml":        {lineComments: []string{"#"}},
+	"toml":       {lineComments: []string{"#"}},
+	"sql":        {keywords: words(`select from where insert into values update set delete create table drop alter join left right inner outer on group by order having limit as and or not null is in`), lineComments: []string{"--"}},
+}
+
+// highlight highlights a line of code in the given language: keywords,
+// strings, numbers and comments. Lines of unknown languages only have their
+// strings and numbers highlighted. Each line is highlighted on its own, so
+// strings and comments spanning lines aren't recognized.
+func highlight(line string, lang string) string {
+	syn, ok := syntaxes[lang]
+	if !ok {
+		syn = genericSyntax
+	}
+
+	var sb strings.Builder
+	runes := []rune(line)
+	for i := 0; i < len(runes); {
+		rest := string(runes[i:])
+		r := runes[i]
+
+		comment := false
+		for _, prefix := range syn.lineComments {
+			if strings.HasPrefix(rest, prefix) {
+				comment = true
+			}
+		}
+		switch {
+		case comment:
+			sb.WriteString(dim + italic + rest + noItalic + noBold)
+			return sb.String()
+		case r == '"' || r == '\'' || (r == '`' && syn.backtickString):
+			j := i + 1
+			for j < len(runes) && runes[j] != r {
+				if runes[j] == '\\' && r != '`' {
+					j++
+				}
+				j++
+			}
+			j = min(j+1, len(runes))
+			sb.WriteString(green + string(runes[i:j]) + defaultColor)
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+			word := string(runes[i:j])
+			if syn.keywords[word] {
+				sb.WriteString(magenta + word + defaultColor)
+			} else {
+				sb.WriteString(word)
+			}
+			i = j
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || unicode.IsLetter(runes[j]) || runes[j] == '.' || runes[j] == '_') {
+				j++
+			}
+			sb.WriteString(cyan + string(runes[i:j]) + defaultColor)
+			i = j
+		default:
+			sb.WriteRune(r)
+			i++
+		}
+	}
+	return sb.String()
+}
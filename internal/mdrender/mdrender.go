@@ -0,0 +1,300 @@
+// Package mdrender renders Markdown for display in terminals, with ANSI
+// escape sequences for styling.
+//
+// It renders the Markdown constructs models commonly use in their responses:
+// headings, emphasis, inline code, links, lists, block quotes, horizontal
+// rules, tables and fenced code blocks (with basic syntax highlighting).
+// Other text is passed through as is. The rendering works line by line, so
+// it can be applied to responses while they're streamed; see [Stream].
+package mdrender
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ANSI escape sequences for styling.
+const (
+	reset         = "\x1b[0m"
+	bold          = "\x1b[1m"
+	dim           = "\x1b[2m"
+	italic        = "\x1b[3m"
+	underline     = "\x1b[4m"
+	strikethrough = "\x1b[9m"
+	noBold        = "\x1b[22m"
+	noItalic      = "\x1b[23m"
+	noUnderline   = "\x1b[24m"
+	noStrike      = "\x1b[29m"
+	defaultColor  = "\x1b[39m"
+
+	green   = "\x1b[32m"
+	yellow  = "\x1b[33m"
+	blue    = "\x1b[34m"
+	magenta = "\x1b[35m"
+	cyan    = "\x1b[36m"
+)
+
+// Render renders the Markdown text md.
+func Render(md string) string {
+	var r renderer
+	var sb strings.Builder
+	lines := strings.SplitAfter(md, "\n")
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		sb.WriteString(r.line(strings.TrimSuffix(line, "\n")))
+	}
+	sb.WriteString(r.flush())
+
+	// The rendered text ends with a newline only if md does.
+	out := sb.String()
+	if !strings.HasSuffix(md, "\n") {
+		out = strings.TrimSuffix(out, "\n")
+	}
+	return out
+}
+
+// renderer renders Markdown a line at a time, keeping the state of blocks
+// that span multiple lines.
+type renderer struct {
+	// inCode is set inside fenced code blocks; fence is the fence that
+	// started the block, and lang is its language.
+	inCode bool
+	fence  string
+	lang   string
+
+	// table has the lines of the table being rendered; tables are rendered
+	// when they end, since the widths of their columns depend on all of
+	// their rows.
+	table []string
+}
+
+var (
+	fenceRe      = regexp.MustCompile("^\\s*(```+|~~~+)\\s*([\\w+#.-]*)")
+	headingRe    = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	bulletRe     = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	numberedRe   = regexp.MustCompile(`^(\s*)(\d+[.)])\s+(.*)$`)
+	taskRe       = regexp.MustCompile(`^\[([ xX])\]\s+(.*)$`)
+	quoteRe      = regexp.MustCompile(`^\s*>\s?(.*)$`)
+	ruleRe       = regexp.MustCompile(`^\s*([-*_])(\s*([-*_])){2,}\s*$`)
+	tableRowRe   = regexp.MustCompile(`^\s*\|.*\|\s*$`)
+	tableDelimRe = regexp.MustCompile(`^\s*:?-+:?\s*$`)
+)
+
+// line renders a single line of Markdown (without its newline), and returns
+// the rendered text to output, which may be empty (when the line is part of
+// a block that's rendered later) or contain several lines.
+func (r *renderer) line(line string) string {
+	if r.inCode {
+		if strings.HasPrefix(strings.TrimSpace(line), r.fence) && strings.Trim(strings.TrimSpace(line), r.fence[:1]) == "" {
+			r.inCode = false
+			return dim + line + noBold + "\n"
+		}
+		return highlight(line, r.lang) + "\n"
+	}
+
+	if tableRowRe.MatchString(line) {
+		r.table = append(r.table, line)
+		return ""
+	}
+	out := r.flush()
+
+	if m := fenceRe.FindStringSubmatch(line); m != nil {
+		r.inCode = true
+		r.fence = m[1]
+		r.lang = strings.ToLower(m[2])
+		return out + dim + line + noBold + "\n"
+	}
+	return out + renderLine(line) + "\n"
+}
+
+// flush renders the pending block (a table), if any.
+func (r *renderer) flush() string {
+	if len(r.table) == 0 {
+		return ""
+	}
+	out := renderTable(r.table)
+	r.table = nil
+	return out
+}
+
+// renderLine renders a line that isn't part of a multi-line block.
+func renderLine(line string) string {
+	if m := headingRe.FindStringSubmatch(line); m != nil {
+		style := bold
+		switch len(m[1]) {
+		case 1:
+			style = bold + underline + cyan
+		case 2:
+			style = bold + cyan
+		case 3:
+			style = bold + blue
+		}
+		return style + inline(m[2]) + reset
+	}
+	if ruleRe.MatchString(line) {
+		return dim + strings.Repeat("─", 40) + noBold
+	}
+	if m := bulletRe.FindStringSubmatch(line); m != nil {
+		text := m[2]
+		marker := "•"
+		if tm := taskRe.FindStringSubmatch(text); tm != nil {
+			marker = "☐"
+			if tm[1] != " " {
+				marker = "☑"
+			}
+			text = tm[2]
+		}
+		return m[1] + yellow + marker + defaultColor + " " + inline(text)
+	}
+	if m := numberedRe.FindStringSubmatch(line); m != nil {
+		return m[1] + yellow + m[2] + defaultColor + " " + inline(m[3])
+	}
+	if m := quoteRe.FindStringSubmatch(line); m != nil {
+		return dim + "│ " + noBold + italic + inline(m[1]) + noItalic
+	}
+	return inline(line)
+}
+
+var (
+	boldRe   = regexp.MustCompile(`\*\*([^*]+?)\*\*|__([^_]+?)__`)
+	italicRe = regexp.MustCompile(`(^|[^\w*])\*([^*\s][^*]*?)\*|(^|[^\w])_([^_\s][^_]*?)_($|[^\w])`)
+	strikeRe = regexp.MustCompile(`~~([^~]+?)~~`)
+	linkRe   = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+)
+
+// inline renders the inline Markdown of text: code spans, emphasis and
+// links.
+func inline(text string) string {
+	// Code spans are rendered as is, so the text is split around them first.
+	var sb strings.Builder
+	for {
+		start := strings.Index(text, "`")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(text[start+1:], "`")
+		if end < 0 {
+			break
+		}
+		end += start + 1
+		sb.WriteString(emphasis(text[:start]))
+		sb.WriteString(yellow + text[start+1:end] + defaultColor)
+		text = text[end+1:]
+	}
+	sb.WriteString(emphasis(text))
+	return sb.String()
+}
+
+// emphasis renders emphasis and links in text.
+func emphasis(text string) string {
+	text = linkRe.ReplaceAllString(text, underline+"$1"+noUnderline+dim+" ($2)"+noBold)
+	text = boldRe.ReplaceAllString(text, bold+"$1$2"+noBold)
+	text = italicRe.ReplaceAllString(text, "$1$3"+italic+"$2$4"+noItalic+"$5")
+	text = strikeRe.ReplaceAllString(text, strikethrough+"$1"+noStrike)
+	return text
+}
+
+// renderTable renders the lines of a table. Lines that don't make up a
+// proper table (with a delimiter row after the header) are rendered as
+// text.
+func renderTable(lines []string) string {
+	var rows [][]string
+	for _, line := range lines {
+		rows = append(rows, splitTableRow(line))
+	}
+	if len(rows) < 2 || !isDelimiterRow(rows[1]) {
+		var sb strings.Builder
+		for _, line := range lines {
+			sb.WriteString(inline(line) + "\n")
+		}
+		return sb.String()
+	}
+
+	header, body := rows[0], rows[2:]
+	ncols := len(header)
+	for _, row := range body {
+		ncols = max(ncols, len(row))
+	}
+	rendered := make([][]string, 0, len(rows)-1)
+	widths := make([]int, ncols)
+	for _, row := range append([][]string{header}, body...) {
+		cells := make([]string, ncols)
+		for i := range cells {
+			if i < len(row) {
+				cells[i] = inline(row[i])
+			}
+			widths[i] = max(widths[i], Width(cells[i]))
+		}
+		rendered = append(rendered, cells)
+	}
+
+	var sb strings.Builder
+	writeRow := func(cells []string, style, unstyle string) {
+		for i, cell := range cells {
+			if i > 0 {
+				sb.WriteString(dim + " │ " + noBold)
+			}
+			sb.WriteString(style + cell + unstyle)
+			if i < len(cells)-1 {
+				sb.WriteString(strings.Repeat(" ", widths[i]-Width(cell)))
+			}
+		}
+		sb.WriteString("\n")
+	}
+	writeRow(rendered[0], bold, noBold)
+	sb.WriteString(dim)
+	for i, w := range widths {
+		if i > 0 {
+			sb.WriteString("─┼─")
+		}
+		sb.WriteString(strings.Repeat("─", w))
+	}
+	sb.WriteString(noBold + "\n")
+	for _, cells := range rendered[1:] {
+		writeRow(cells, "", "")
+	}
+	return sb.String()
+}
+
+// splitTableRow splits a table row into its cells.
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	line = strings.TrimSuffix(line, "|")
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+func isDelimiterRow(cells []string) bool {
+	for _, cell := range cells {
+		if !tableDelimRe.MatchString(cell) {
+			return false
+		}
+	}
+	return true
+}
+
+var escapeRe = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// Width returns the number of terminal columns s takes, ignoring ANSI escape
+// sequences. Every rune is assumed to take a single column.
+func Width(s string) int {
+	return utf8.RuneCountInString(escapeRe.ReplaceAllString(s, ""))
+}
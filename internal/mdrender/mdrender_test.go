@@ -0,0 +1,122 @@
+package mdrender
+
+import (
+	"strings"
+	"testing"
+)
+
+// plain removes the ANSI escape sequences from s.
+func plain(s string) string {
+	return escapeRe.ReplaceAllString(s, "")
+}
+
+func TestRender(t *testing.T) {
+	var tests = []struct {
+		name string
+		md   string
+		want string
+	}{
+		{"text", "just text", "just text"},
+		{"newline", "line\n", "line\n"},
+		{"heading", "## Title ##", bold + cyan + "Title" + reset},
+		{"bold", "a **b** c", "a " + bold + "b" + noBold + " c"},
+		{"italic", "an *emphasized* word", "an " + italic + "emphasized" + noItalic + " word"},
+		{"underscore italic", "an _emphasized_ word", "an " + italic + "emphasized" + noItalic + " word"},
+		{"snake_case", "call my_func_name now", "call my_func_name now"},
+		{"multiplication", "2 * 3 * 4", "2 * 3 * 4"},
+		{"code span", "run `go **test**`", "run " + yellow + "go **test**" + defaultColor},
+		{"link", "[Go](https://go.dev)", underline + "Go" + noUnderline + dim + " (https://go.dev)" + noBold},
+		{"bullet", "  - item", "  " + yellow + "•" + defaultColor + " item"},
+		{"numbered", "2. second", yellow + "2." + defaultColor + " second"},
+		{"task", "- [x] done", yellow + "☑" + defaultColor + " done"},
+		{"rule", "***", dim + strings.Repeat("─", 40) + noBold},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.md); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderBlocks(t *testing.T) {
+	md := "Some code:\n\n```go\nfunc f() string { return \"x\" } // done\n```\n\n| name | n |\n|---|--:|\n| a | 10 |\n| bbb | 2 |\n\nafter"
+	want := "Some code:\n\n```go\nfunc f() string { return \"x\" } // done\n```\n\nname │ n\n─────┼───\na    │ 10\nbbb  │ 2\n\nafter"
+	got := Render(md)
+	if plain(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", plain(got), want)
+	}
+
+	// Keywords, strings and comments in code are highlighted.
+	for _, s := range []string{magenta + "func" + defaultColor, green + `"x"` + defaultColor, dim + italic + "// done"} {
+		if !strings.Contains(got, s) {
+			t.Errorf("expected %q in rendered code %q", s, got)
+		}
+	}
+	// Markdown isn't rendered inside code blocks.
+	if got := Render("```\n**not bold**\n```"); strings.Contains(got, bold) {
+		t.Errorf("got bold text in code block: %q", got)
+	}
+}
+
+func TestRenderNotTable(t *testing.T) {
+	md := "| not | a table |\nnext"
+	if got := plain(Render(md)); got != md {
+		t.Errorf("got %q, want %q", got, md)
+	}
+}
+
+func TestStream(t *testing.T) {
+	md := "# Title\n\nSome **bold** text and a table:\n\n| a | b |\n|---|---|\n| 1 | 2 |\n\n```sh\necho hi\n```\nthe end"
+
+	// Write md in chunks of various sizes; the final screen contents should
+	// be the same as rendering it all at once.
+	for _, size := range []int{1, 3, 7, 100} {
+		var sb strings.Builder
+		s := NewStream(&sb, 80)
+		for i := 0; i < len(md); i += size {
+			s.Write([]byte(md[i:min(i+size, len(md))]))
+		}
+		if err := s.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := screen(sb.String()), Render(md); got != want {
+			t.Errorf("chunk size %d: got screen\n%q\nwant\n%q", size, got, want)
+		}
+	}
+}
+
+// screen simulates the effect of the cursor movement escape sequences Stream
+// uses on the text of a terminal, and returns the final text.
+func screen(out string) string {
+	var lines []string
+	cur := ""
+	for len(out) > 0 {
+		switch {
+		case strings.HasPrefix(out, "\r"):
+			out = out[1:]
+		case strings.HasPrefix(out, "\x1b[J"):
+			cur = ""
+			out = out[3:]
+		case strings.HasPrefix(out, "\x1b[") && strings.Contains(out[:min(len(out), 8)], "A"):
+			end := strings.Index(out, "A")
+			var n int
+			for _, c := range out[2:end] {
+				n = n*10 + int(c-'0')
+			}
+			lines = lines[:len(lines)-n]
+			out = out[end+1:]
+		case out[0] == '\n':
+			lines = append(lines, cur)
+			cur = ""
+			out = out[1:]
+		default:
+			cur += out[:1]
+			out = out[1:]
+		}
+	}
+	return strings.Join(append(lines, cur), "\n")
+}
@@ -0,0 +1,107 @@
+package mdrender
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Stream renders Markdown written to it in pieces, like the chunks of a
+// streamed response, to a terminal.
+//
+// Complete lines are rendered as soon as they're written. The text of the
+// current block (an incomplete line, or the rows of a table seen so far) is
+// shown as is while it's written, and replaced by its rendering when it's
+// complete; this requires the output to be a terminal that understands ANSI
+// cursor movement.
+type Stream struct {
+	w     io.Writer
+	width int
+	r     renderer
+
+	// partial is the incomplete last line written.
+	partial string
+
+	// shown is the raw text of the current block shown in the terminal, to
+	// erase when the block is rendered.
+	shown string
+}
+
+// NewStream creates a Stream writing to w, a terminal width columns wide.
+func NewStream(w io.Writer, width int) *Stream {
+	if width <= 0 {
+		width = 80
+	}
+	return &Stream{w: w, width: width}
+}
+
+// Write renders the Markdown text in p, which continues the text written
+// before it.
+func (s *Stream) Write(p []byte) (int, error) {
+	text := s.partial + string(p)
+	var rendered strings.Builder
+	for {
+		nl := strings.IndexByte(text, '\n')
+		if nl < 0 {
+			break
+		}
+		rendered.WriteString(s.r.line(text[:nl]))
+		text = text[nl+1:]
+	}
+	s.partial = text
+
+	var pending []string
+	pending = append(pending, s.r.table...)
+	if s.partial != "" || len(pending) > 0 {
+		pending = append(pending, s.partial)
+	}
+	if err := s.update(rendered.String(), strings.Join(pending, "\n")); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush renders the current block, even if it's incomplete.
+func (s *Stream) Flush() error {
+	var rendered strings.Builder
+	if s.partial != "" {
+		// Like the incomplete line, its rendering doesn't end with a newline.
+		rendered.WriteString(strings.TrimSuffix(s.r.line(s.partial)+s.r.flush(), "\n"))
+		s.partial = ""
+	} else {
+		rendered.WriteString(s.r.flush())
+	}
+	return s.update(rendered.String(), "")
+}
+
+// update writes the newly rendered text, followed by the raw text of the
+// current block.
+func (s *Stream) update(rendered, pending string) error {
+	var out string
+	if rendered == "" && strings.HasPrefix(pending, s.shown) {
+		// Only more raw text was written.
+		out = pending[len(s.shown):]
+	} else {
+		out = s.erase() + rendered + pending
+	}
+	s.shown = pending
+	_, err := io.WriteString(s.w, out)
+	return err
+}
+
+// erase returns the escape sequences that erase the raw text shown for the
+// current block, leaving the cursor where it started.
+func (s *Stream) erase() string {
+	if s.shown == "" {
+		return ""
+	}
+	rows := 0
+	for _, line := range strings.Split(s.shown, "\n") {
+		rows += max(1, (Width(line)+s.width-1)/s.width)
+	}
+	out := "\r"
+	if rows > 1 {
+		out += fmt.Sprintf("\x1b[%dA", rows-1)
+	}
+	return out + "\x1b[J"
+}
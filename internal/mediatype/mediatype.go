@@ -0,0 +1,251 @@
+// Package mediatype detects the media (MIME) types of files sent to models,
+// and knows which types models accept as inline data.
+package mediatype
+
+import (
+	"bytes"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// Text is the media type of plain text.
+const Text = "text/plain"
+
+// Unknown is the media type of binary data of unknown type.
+const Unknown = "application/octet-stream"
+
+// supported are the media types models accept as inline data, named as the
+// Gemini API names them.
+var supported = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/webp":      true,
+	"image/heic":      true,
+	"image/heif":      true,
+	"image/gif":       true,
+	"application/pdf": true,
+	"audio/mp3":       true,
+	"audio/wav":       true,
+	"audio/flac":      true,
+	"audio/aiff":      true,
+	"audio/ogg":       true,
+	"video/mp4":       true,
+	"video/mov":       true,
+	"video/webm":      true,
+	"video/avi":       true,
+}
+
+// aliases maps media types reported by sniffing to the names the Gemini
+// API uses for them.
+var aliases = map[string]string{
+	"audio/mpeg":      "audio/mp3",
+	"audio/wave":      "audio/wav",
+	"audio/x-wav":     "audio/wav",
+	"audio/x-flac":    "audio/flac",
+	"application/ogg": "audio/ogg",
+	"video/quicktime": "video/mov",
+}
+
+// extensions maps file extensions to media types, for data that can't be
+// identified by its content.
+var extensions = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".webp": "image/webp",
+	".heic": "image/heic",
+	".heif": "image/heif",
+	".gif":  "image/gif",
+	".pdf":  "application/pdf",
+	".mp3":  "audio/mp3",
+	".wav":  "audio/wav",
+	".flac": "audio/flac",
+	".aiff": "audio/aiff",
+	".ogg":  "audio/ogg",
+	".mp4":  "video/mp4",
+	".mov":  "video/mov",
+	".webm": "video/webm",
+	".avi":  "video/avi",
+}
+
+// textExtensions are the extensions of text files, for text in legacy
+// encodings that can't be identified as text by its content.
+var textExtensions = map[string]bool{
+	".txt": true, ".text": true, ".md": true, ".rst": true, ".csv": true,
+	".tsv": true, ".log": true, ".ini": true, ".cfg": true, ".conf": true,
+	".json": true, ".yaml": true, ".yml": true, ".toml": true, ".xml": true,
+	".html": true, ".htm": true, ".css": true, ".tex": true, ".srt": true,
+	".vtt": true, ".sql": true, ".sh": true, ".bat": true, ".c": true,
+	".h": true, ".cc": true, ".cpp": true, ".java": true, ".js": true,
+	".ts": true, ".go": true, ".py": true, ".rb": true, ".rs": true,
+}
+
+// legacyText is the result of sniff for data that looks like text, but is
+// neither UTF-8 nor UTF-16 with a byte order mark.
+const legacyText = "text/plain; charset=unknown"
+
+// Detect returns the media type of data, which was read from a file with
+// the given name. The type is detected from the content, falling back to
+// the name's extension for binary data that can't be identified. Text is
+// reported as [Text] (see [DecodeText] for its encodings), and unidentified
+// binary data as [Unknown].
+func Detect(name string, data []byte) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	mt := sniff(data)
+	if mt == legacyText {
+		// Text in a legacy 8-bit encoding (like Latin-1) can't be told apart
+		// from binary data by its content; it's taken as text if the file
+		// name says so.
+		if textExtensions[ext] {
+			return Text
+		}
+		mt = Unknown
+	}
+	if mt != Unknown {
+		return mt
+	}
+	if mt, ok := extensions[ext]; ok {
+		return mt
+	}
+	return Unknown
+}
+
+// SniffLen is the number of bytes at the start of a file that [DetectHead]
+// looks at.
+const SniffLen = 512
+
+// DetectHead is like [Detect] for a file that's too large to read in full;
+// head holds its first [SniffLen] bytes (or all of it, if it's shorter).
+// Since only the head is checked, a file is reported as [Text] if its head is
+// valid UTF-8, even if an invalid sequence follows.
+func DetectHead(name string, head []byte) string {
+	if len(head) > SniffLen {
+		head = head[:SniffLen]
+	}
+	// The head may end in the middle of a multi-byte character.
+	for i := 1; i <= utf8.UTFMax-1 && i <= len(head); i++ {
+		if utf8.RuneStart(head[len(head)-i]) {
+			if !utf8.FullRune(head[len(head)-i:]) {
+				head = head[:len(head)-i]
+			}
+			break
+		}
+	}
+	return Detect(name, head)
+}
+
+// DecodeText returns data, detected as [Text] by [Detect], as a UTF-8
+// string. Text starting with a UTF-16 byte order mark is decoded from
+// UTF-16, and other text that isn't valid UTF-8 is decoded from Windows-1252
+// (a superset of Latin-1, and the most common legacy encoding). A UTF-8 byte
+// order mark is dropped.
+func DecodeText(data []byte) string {
+	if hasUTF16BOM(data) {
+		dec := unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM).NewDecoder()
+		if text, err := dec.Bytes(data); err == nil {
+			return string(text)
+		}
+	}
+	data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	// Every byte is a valid Windows-1252 character, so decoding can't fail.
+	text, _ := charmap.Windows1252.NewDecoder().Bytes(data)
+	return string(text)
+}
+
+// hasUTF16BOM reports whether data starts with a UTF-16 byte order mark, in
+// either byte order.
+func hasUTF16BOM(data []byte) bool {
+	return bytes.HasPrefix(data, []byte{0xFF, 0xFE}) || bytes.HasPrefix(data, []byte{0xFE, 0xFF})
+}
+
+// IsSupported reports whether models accept inline data of the given media
+// type.
+func IsSupported(mediaType string) bool {
+	return supported[mediaType]
+}
+
+// Normalize returns the name the Gemini API uses for mediaType, which may be
+// an alternative name for the same type (like "audio/mpeg" for "audio/mp3").
+// Parameters and case are ignored.
+func Normalize(mediaType string) string {
+	mt, _, _ := strings.Cut(mediaType, ";")
+	mt = strings.ToLower(strings.TrimSpace(mt))
+	if alias, ok := aliases[mt]; ok {
+		return alias
+	}
+	return mt
+}
+
+// IsText reports whether the given media type is textual.
+func IsText(mediaType string) bool {
+	return strings.HasPrefix(mediaType, "text/")
+}
+
+// sniff detects the media type of data from its content.
+func sniff(data []byte) string {
+	// A UTF-16 byte order mark looks like an MPEG audio frame header to
+	// sniffExtra.
+	if hasUTF16BOM(data) {
+		return Text
+	}
+	if mt := sniffExtra(data); mt != "" {
+		return mt
+	}
+
+	mt := http.DetectContentType(data)
+	if strings.HasPrefix(mt, "text/") {
+		// The exact kind of text (like HTML) doesn't matter to models.
+		// DetectContentType only looks at the first 512 bytes, and doesn't
+		// check their encoding, so UTF-8 text has to be valid throughout.
+		if utf8.Valid(data) {
+			return Text
+		}
+		return legacyText
+	}
+	if alias, ok := aliases[mt]; ok {
+		return alias
+	}
+	return mt
+}
+
+// isoBrands maps the major brands of ISO base media files (identified by an
+// 'ftyp' box) to media types, for brands that DetectContentType doesn't
+// know.
+var isoBrands = map[string]string{
+	"heic": "image/heic",
+	"heix": "image/heic",
+	"heim": "image/heic",
+	"heis": "image/heic",
+	"hevc": "image/heic",
+	"hevx": "image/heic",
+	"mif1": "image/heif",
+	"msf1": "image/heif",
+	"qt  ": "video/mov",
+}
+
+// sniffExtra detects media types that [http.DetectContentType] doesn't. It
+// returns "" if data isn't of any of these types.
+func sniffExtra(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("fLaC")):
+		return "audio/flac"
+	case len(data) >= 12 && string(data[4:8]) == "ftyp":
+		return isoBrands[string(data[8:12])]
+	case len(data) >= 8 && (string(data[4:8]) == "moov" || string(data[4:8]) == "mdat" || string(data[4:8]) == "wide"):
+		// QuickTime files predating the 'ftyp' box.
+		return "video/mov"
+	case len(data) >= 2 && data[0] == 0xFF && data[1]&0xE0 == 0xE0 && data[1]&0x06 != 0:
+		// An MPEG audio frame header (with a layer set), which starts MP3 files
+		// without ID3 tags.
+		return "audio/mp3"
+	}
+	return ""
+}
@@ -0,0 +1,140 @@
+package mediatype
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	var tests = []struct {
+		name string
+		data string
+		want string
+	}{
+		{"a.txt", "hello world\n", Text},
+		{"a.md", "# Title\n\nsome *text*", Text},
+		{"noext", "plain text without extension", Text},
+		{"a.html", "<html><body>hi</body></html>", Text},
+		{"empty.bin", "", Text},
+		{"utf8.txt", "héllo wörld ✓", Text},
+
+		{"a.png", "\x89PNG\x0D\x0A\x1A\x0A....", "image/png"},
+		{"a.jpg", "\xFF\xD8\xFF\xE0....", "image/jpeg"},
+		{"a.gif", "GIF89a....", "image/gif"},
+		{"a.webp", "RIFF\x00\x00\x00\x00WEBPVP", "image/webp"},
+		{"a.heic", "\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic", "image/heic"},
+		{"a.heif", "\x00\x00\x00\x18ftypmif1\x00\x00\x00\x00mif1heic", "image/heif"},
+		{"a.pdf", "%PDF-1.7\n....", "application/pdf"},
+		{"a.mp3", "ID3\x03\x00....", "audio/mp3"},
+		{"b.mp3", "\xFF\xFB\x90\x44....", "audio/mp3"},
+		{"a.wav", "RIFF\x00\x00\x00\x00WAVEfmt ", "audio/wav"},
+		{"a.flac", "fLaC\x00\x00\x00\x22", "audio/flac"},
+		{"a.mp4", "\x00\x00\x00\x18ftypisom\x00\x00\x02\x00isommp41", "video/mp4"},
+		{"a.mov", "\x00\x00\x00\x14ftypqt  \x00\x00\x00\x00qt  ", "video/mov"},
+		{"b.mov", "\x00\x00\x00\x08wide\x00\x00\x00\x00mdat", "video/mov"},
+
+		// Content wins over the extension.
+		{"actually-png.txt", "\x89PNG\x0D\x0A\x1A\x0A....", "image/png"},
+		{"actually-text.png", "just text", Text},
+
+		// Unidentified binary data falls back to the extension.
+		{"raw.mp3", "\x00\x01\x02\x03garbage", "audio/mp3"},
+		{"RAW.MOV", "\x00\x01\x02\x03garbage", "video/mov"},
+		{"a.bin", "\x00\x01\x02\x03garbage", Unknown},
+		// Text in legacy encodings is recognized by its extension, and text in
+		// UTF-16 by its byte order mark.
+		{"latin1.txt", "h\xe9llo", Text},
+		{"latin1.csv", "caf\xe9,1\n", Text},
+		{"latin1", "h\xe9llo", Unknown},
+		{"latin1.bin", "h\xe9llo", Unknown},
+		{"utf16le", "\xFF\xFEh\x00i\x00", Text},
+		{"utf16be.dat", "\xFE\xFF\x00h\x00i", Text},
+		{"a.zip", "PK\x03\x04....", "application/zip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Detect(tt.name, []byte(tt.data))
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsSupported(t *testing.T) {
+	for _, mt := range []string{"image/png", "image/heic", "application/pdf", "audio/mp3", "audio/flac", "video/mov"} {
+		if !IsSupported(mt) {
+			t.Errorf("IsSupported(%q) = false, want true", mt)
+		}
+	}
+	for _, mt := range []string{Text, Unknown, "application/zip", "image/bmp"} {
+		if IsSupported(mt) {
+			t.Errorf("IsSupported(%q) = true, want false", mt)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	var tests = []struct {
+		mediaType string
+		want      string
+	}{
+		{"image/png", "image/png"},
+		{"IMAGE/PNG", "image/png"},
+		{"application/pdf; charset=binary", "application/pdf"},
+		{"audio/mpeg", "audio/mp3"},
+		{"video/quicktime", "video/mov"},
+		{"text/html; charset=utf-8", "text/html"},
+	}
+
+	for _, tt := range tests {
+		if got := Normalize(tt.mediaType); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.mediaType, got, tt.want)
+		}
+	}
+}
+
+func TestDetectHead(t *testing.T) {
+	var tests = []struct {
+		name string
+		head string
+		want string
+	}{
+		{"a.txt", "hello world\n", Text},
+		// A multi-byte character cut at the end of the head.
+		{"noext", strings.Repeat("a", SniffLen-1) + "é", Text},
+		{"noext", strings.Repeat("a", SniffLen-2) + "✓", Text},
+		{"noext", strings.Repeat("a", 100) + "\xe9" + strings.Repeat("a", 100), Unknown},
+		{"a.png", "\x89PNG\x0D\x0A\x1A\x0A....", "image/png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectHead(tt.name, []byte(tt.head)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeText(t *testing.T) {
+	var tests = []struct {
+		name string
+		data string
+		want string
+	}{
+		{"utf8", "héllo ✓", "héllo ✓"},
+		{"utf8 bom", "\xEF\xBB\xBFhello", "hello"},
+		{"utf16le", "\xFF\xFEh\x00\xe9\x00", "hé"},
+		{"utf16be", "\xFE\xFF\x00h\x00\xe9", "hé"},
+		{"latin1", "caf\xe9", "café"},
+		{"windows-1252", "\x93quoted\x94", "\u201cquoted\u201d"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DecodeText([]byte(tt.data)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+// Package pricing estimates the cost of requests to Gemini models from their
+// token usage.
+//
+// Prices are kept in a [Table] mapping model names to their [Price]. The
+// built-in [Default] table has the pay-as-you-go prices of the Gemini API at
+// the time of writing; users can override them (or add prices for other
+// models) in the configuration file. Models without a price, like models
+// released after the table was written, get no cost estimate.
+package pricing
+
+import (
+	"maps"
+	"strings"
+)
+
+// Price is the price of a model, in US dollars per million tokens.
+//
+// Some models are billed at a higher rate for long prompts: prompts longer
+// than LongContextThreshold tokens are billed with the Long* prices, if
+// LongContextThreshold is positive.
+type Price struct {
+	Input       float64 `yaml:"input" json:"input"`
+	Output      float64 `yaml:"output" json:"output"`
+	CachedInput float64 `yaml:"cached_input" json:"cached_input"`
+
+	LongContextThreshold int32   `yaml:"long_context_threshold" json:"long_context_threshold,omitempty"`
+	LongInput            float64 `yaml:"long_input" json:"long_input,omitempty"`
+	LongOutput           float64 `yaml:"long_output" json:"long_output,omitempty"`
+	LongCachedInput      float64 `yaml:"long_cached_input" json:"long_cached_input,omitempty"`
+}
+
+// Usage is the number of tokens used by a request. PromptTokens includes the
+// CachedTokens taken from cached content.
+type Usage struct {
+	PromptTokens   int32
+	CachedTokens   int32
+	ResponseTokens int32
+}
+
+// Cost returns the cost of a request with the usage u, in US dollars.
+func (p Price) Cost(u Usage) float64 {
+	input, output, cached := p.Input, p.Output, p.CachedInput
+	if p.LongContextThreshold > 0 && u.PromptTokens > p.LongContextThreshold {
+		input, output, cached = p.LongInput, p.LongOutput, p.LongCachedInput
+	}
+	fresh := u.PromptTokens - u.CachedTokens
+	cost := float64(fresh)*input + float64(u.CachedTokens)*cached + float64(u.ResponseTokens)*output
+	return cost / 1e6
+}
+
+// Table maps model names to their prices.
+type Table map[string]Price
+
+// Default is the built-in price table.
+var Default = Table{
+	"gemini-2.5-pro": {
+		Input: 1.25, Output: 10.00, CachedInput: 0.125,
+		LongContextThreshold: 200_000,
+		LongInput:            2.50, LongOutput: 15.00, LongCachedInput: 0.25,
+	},
+	"gemini-2.5-flash":      {Input: 0.30, Output: 2.50, CachedInput: 0.03},
+	"gemini-2.5-flash-lite": {Input: 0.10, Output: 0.40, CachedInput: 0.01},
+	"gemini-2.0-flash":      {Input: 0.10, Output: 0.40, CachedInput: 0.025},
+	"gemini-2.0-flash-lite": {Input: 0.075, Output: 0.30},
+
+	"gemini-1.5-flash": {
+		Input: 0.075, Output: 0.30, CachedInput: 0.01875,
+		LongContextThreshold: 128_000,
+		LongInput:            0.15, LongOutput: 0.60, LongCachedInput: 0.0375,
+	},
+	"gemini-1.5-flash-8b": {
+		Input: 0.0375, Output: 0.15, CachedInput: 0.01,
+		LongContextThreshold: 128_000,
+		LongInput:            0.075, LongOutput: 0.30, LongCachedInput: 0.02,
+	},
+	"gemini-1.5-pro": {
+		Input: 1.25, Output: 5.00, CachedInput: 0.3125,
+		LongContextThreshold: 128_000,
+		LongInput:            2.50, LongOutput: 10.00, LongCachedInput: 0.625,
+	},
+	"gemini-1.0-pro": {Input: 0.50, Output: 1.50},
+
+	// Embedding models are free of charge.
+	"text-embedding-004": {},
+	"embedding-001":      {},
+}
+
+// WithOverrides returns a table with the prices of t, replaced by (or
+// extended with) the prices of overrides.
+func (t Table) WithOverrides(overrides Table) Table {
+	merged := maps.Clone(t)
+	if merged == nil {
+		merged = make(Table)
+	}
+	maps.Copy(merged, overrides)
+	return merged
+}
+
+// Lookup returns the price of the model named name, which may have a
+// "models/" prefix. Models without a price of their own use the price of
+// the longest name in t that they extend with a "-suffix"; for example,
+// "gemini-1.5-flash-002" uses the price of "gemini-1.5-flash". It reports
+// whether a price was found.
+func (t Table) Lookup(name string) (Price, bool) {
+	name = strings.TrimPrefix(name, "models/")
+	if p, ok := t[name]; ok {
+		return p, true
+	}
+	var best string
+	for key := range t {
+		if strings.HasPrefix(name, key+"-") && len(key) > len(best) {
+			best = key
+		}
+	}
+	if best == "" {
+		return Price{}, false
+	}
+	return t[best], true
+}
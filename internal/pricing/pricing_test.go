@@ -0,0 +1,111 @@
+package pricing
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCost(t *testing.T) {
+	p := Price{
+		Input: 1, Output: 4, CachedInput: 0.25,
+		LongContextThreshold: 1000,
+		LongInput:            2, LongOutput: 8, LongCachedInput: 0.5,
+	}
+	var tests = []struct {
+		name string
+		u    Usage
+		want float64
+	}{
+		{"empty", Usage{}, 0},
+		{"short", Usage{PromptTokens: 1000, ResponseTokens: 500}, (1000*1 + 500*4) / 1e6},
+		{"cached", Usage{PromptTokens: 1000, CachedTokens: 800, ResponseTokens: 10}, (200*1 + 800*0.25 + 10*4) / 1e6},
+		{"long", Usage{PromptTokens: 1001, ResponseTokens: 10}, (1001*2 + 10*8) / 1e6},
+		{"long cached", Usage{PromptTokens: 2000, CachedTokens: 1000}, (1000*2 + 1000*0.5) / 1e6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.Cost(tt.u); math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Without a long context threshold, the regular prices always apply.
+	flat := Price{Input: 1, Output: 2}
+	if got, want := flat.Cost(Usage{PromptTokens: 1_000_000, ResponseTokens: 1_000_000}), 3.0; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	table := Table{
+		"gemini-1.5-flash":    {Input: 1},
+		"gemini-1.5-flash-8b": {Input: 2},
+		"gemini-1.5-pro":      {Input: 3},
+	}
+	var tests = []struct {
+		name      string
+		wantInput float64
+		wantOK    bool
+	}{
+		{"gemini-1.5-flash", 1, true},
+		{"models/gemini-1.5-flash", 1, true},
+		{"gemini-1.5-flash-002", 1, true},
+		{"gemini-1.5-flash-latest", 1, true},
+		{"gemini-1.5-flash-8b", 2, true},
+		{"gemini-1.5-flash-8b-001", 2, true},
+		{"gemini-1.5-pro-exp-0827", 3, true},
+		{"gemini-1.5-flashy", 0, false},
+		{"gemini-2.0", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, ok := table.Lookup(tt.name)
+			if ok != tt.wantOK || p.Input != tt.wantInput {
+				t.Errorf("got %v, %v; want input %v, %v", p, ok, tt.wantInput, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestWithOverrides(t *testing.T) {
+	base := Table{"a": {Input: 1}, "b": {Input: 2}}
+	merged := base.WithOverrides(Table{"b": {Input: 20}, "c": {Input: 30}})
+
+	for name, want := range map[string]float64{"a": 1, "b": 20, "c": 30} {
+		if got := merged[name].Input; got != want {
+			t.Errorf("%s: got input %v, want %v", name, got, want)
+		}
+	}
+	if base["b"].Input != 2 {
+		t.Errorf("base table modified: %v", base)
+	}
+
+	if got := Table(nil).WithOverrides(Table{"a": {Input: 1}}); len(got) != 1 {
+		t.Errorf("got %v, want a single price", got)
+	}
+}
+
+func TestDefault(t *testing.T) {
+	// Every model family has its own price, and versions of models use the
+	// price of their family.
+	for name, family := range map[string]string{
+		"gemini-2.5-pro":                 "gemini-2.5-pro",
+		"gemini-2.5-flash-preview-05-20": "gemini-2.5-flash",
+		"gemini-2.5-flash-lite":          "gemini-2.5-flash-lite",
+		"gemini-2.0-flash-001":           "gemini-2.0-flash",
+		"gemini-2.0-flash-lite-001":      "gemini-2.0-flash-lite",
+		"models/gemini-1.5-flash-002":    "gemini-1.5-flash",
+	} {
+		p, ok := Default.Lookup(name)
+		if !ok || p != Default[family] {
+			t.Errorf("%s: got %v, %v; want the price of %s", name, p, ok, family)
+		}
+	}
+
+	if p, ok := Default.Lookup("gemma-3-27b-it"); ok {
+		t.Errorf("gemma-3-27b-it: got %v, want no price", p)
+	}
+}
@@ -0,0 +1,394 @@
+// Package promptlog implements the log of prompts and responses that
+// gemini-cli keeps in a SQLite database.
+//
+// Every exchange with a model (a prompt and its response) is stored as an
+// [Entry]. Binary prompt parts like images are stored once per distinct
+// content, keyed by the SHA-256 hash of their data, and entries refer to
+// them by hash.
+package promptlog
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/eliben/gemini-cli/internal/config"
+	_ "modernc.org/sqlite"
+)
+
+// FileName is the name of the log database inside [config.DataDir].
+const FileName = "log.db"
+
+// Entry is a single exchange with a model.
+type Entry struct {
+	ID   int64     `json:"id"`
+	Time time.Time `json:"time"`
+
+	// Command is the gemini-cli command that made the exchange, like "prompt"
+	// or "chat".
+	Command string `json:"command"`
+
+	// Conversation identifies the chat session an entry belongs to; all the
+	// exchanges of a single chat session have the same Conversation.
+	Conversation string `json:"conversation,omitempty"`
+
+	Model string `json:"model"`
+
+	// System is the system instruction the prompt was sent with, if any.
+	System string `json:"system,omitempty"`
+
+	// History holds the turns the prompt was sent after, for prompts sent
+	// with the turns of a conversation file. (The exchanges of a chat session
+	// are logged as entries of their own instead.)
+	History []Turn `json:"history,omitempty"`
+	Parts   []Part `json:"parts"`
+
+	// Response is the text of the model's response. For requests with
+	// multiple candidates, it's the text of the first one.
+	Response     string        `json:"response"`
+	FinishReason string        `json:"finish_reason,omitempty"`
+	Usage        Usage         `json:"usage"`
+	Duration     time.Duration `json:"duration_ns"`
+}
+
+// Part is a part of a prompt. Text parts have Type "text" and carry their
+// Text; binary parts (like images) have Type "blob", and refer to an
+// [Attachment] with their SHA256. Files uploaded with the File API have
+// Type "file", and carry their URI.
+type Part struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	MIMEType string `json:"mime_type,omitempty"`
+	SHA256   string `json:"sha256,omitempty"`
+	Size     int    `json:"size,omitempty"`
+	URI      string `json:"uri,omitempty"`
+}
+
+// Turn is a turn of the history sent before a prompt; Role is "user" or
+// "model".
+type Turn struct {
+	Role  string `json:"role"`
+	Parts []Part `json:"parts"`
+}
+
+// Usage is the number of tokens used by an exchange.
+type Usage struct {
+	PromptTokens   int32 `json:"prompt_tokens"`
+	ResponseTokens int32 `json:"response_tokens"`
+	CachedTokens   int32 `json:"cached_tokens"`
+	TotalTokens    int32 `json:"total_tokens"`
+}
+
+// Attachment is the data of a binary prompt part.
+type Attachment struct {
+	SHA256   string
+	MIMEType string
+	Data     []byte
+}
+
+// NewAttachment creates an attachment for data, computing its hash.
+func NewAttachment(mimeType string, data []byte) *Attachment {
+	sum := sha256.Sum256(data)
+	return &Attachment{SHA256: hex.EncodeToString(sum[:]), MIMEType: mimeType, Data: data}
+}
+
+// Part returns the prompt part referring to a.
+func (a *Attachment) Part() Part {
+	return Part{Type: "blob", MIMEType: a.MIMEType, SHA256: a.SHA256, Size: len(a.Data)}
+}
+
+// PromptText returns the text parts of e's prompt, joined with newlines.
+func (e *Entry) PromptText() string {
+	var texts []string
+	for _, p := range e.Parts {
+		if p.Type == "text" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// ErrNotFound is returned when a requested entry doesn't exist.
+var ErrNotFound = errors.New("log entry not found")
+
+// DB is a log database.
+type DB struct {
+	db *sql.DB
+}
+
+const schema = `
+CREATE TABLE IF NOT EXISTS entries (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	time TEXT NOT NULL,
+	command TEXT NOT NULL,
+	conversation TEXT,
+	model TEXT NOT NULL,
+	system TEXT,
+	history TEXT,
+	parts TEXT NOT NULL,
+	response TEXT NOT NULL,
+	finish_reason TEXT,
+	prompt_tokens INTEGER,
+	response_tokens INTEGER,
+	cached_tokens INTEGER,
+	total_tokens INTEGER,
+	duration_ns INTEGER
+);
+CREATE TABLE IF NOT EXISTS attachments (
+	sha256 TEXT PRIMARY KEY,
+	mime_type TEXT NOT NULL,
+	data BLOB NOT NULL
+);
+CREATE VIRTUAL TABLE IF NOT EXISTS entries_fts USING fts5(prompt, response);
+`
+
+// timeFormat is the format of the times of entries in the database: UTC
+// times with a fixed number of fraction digits, which sort as strings in
+// chronological order. (RFC 3339 times with a varying number of fraction
+// digits don't: "10:00:00Z" sorts after "10:00:00.5Z".)
+const timeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// DefaultPath returns the path of the log database in [config.DataDir].
+func DefaultPath() (string, error) {
+	dir, err := config.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, FileName), nil
+}
+
+// Open opens the log database at path, creating it if it doesn't exist.
+func Open(path string) (*DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// Concurrent gemini-cli processes may write to the log at the same time.
+	if _, err := db.Exec("PRAGMA busy_timeout = 5000"); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating log database %v: %w", path, err)
+	}
+	return &DB{db: db}, nil
+}
+
+// Close closes the database.
+func (db *DB) Close() error {
+	return db.db.Close()
+}
+
+// Add adds e to the log, along with the attachments its parts refer to, and
+// sets e.ID to the ID of the new entry.
+func (db *DB) Add(e *Entry, attachments []*Attachment) error {
+	parts, err := json.Marshal(e.Parts)
+	if err != nil {
+		return err
+	}
+	var history sql.NullString
+	if len(e.History) > 0 {
+		b, err := json.Marshal(e.History)
+		if err != nil {
+			return err
+		}
+		history = sql.NullString{String: string(b), Valid: true}
+	}
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, a := range attachments {
+		_, err := tx.Exec("INSERT OR IGNORE INTO attachments VALUES (?, ?, ?)", a.SHA256, a.MIMEType, a.Data)
+		if err != nil {
+			return err
+		}
+	}
+
+	res, err := tx.Exec(`INSERT INTO entries
+	(time, command, conversation, model, system, history, parts, response, finish_reason,
+	 prompt_tokens, response_tokens, cached_tokens, total_tokens, duration_ns)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.Time.UTC().Format(timeFormat), e.Command, e.Conversation, e.Model, e.System, history, string(parts),
+		e.Response, e.FinishReason,
+		e.Usage.PromptTokens, e.Usage.ResponseTokens, e.Usage.CachedTokens, e.Usage.TotalTokens,
+		int64(e.Duration))
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO entries_fts (rowid, prompt, response) VALUES (?, ?, ?)", id, e.PromptText(), e.Response)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	e.ID = id
+	return nil
+}
+
+const selectEntries = `SELECT
+	id, time, command, conversation, model, system, history, parts, response, finish_reason,
+	prompt_tokens, response_tokens, cached_tokens, total_tokens, duration_ns
+	FROM entries`
+
+// List returns the latest entries in the log, newest first. If limit is
+// positive, at most limit entries are returned.
+func (db *DB) List(limit int) ([]*Entry, error) {
+	return db.queryEntries(selectEntries+" ORDER BY id DESC LIMIT ?", limitArg(limit))
+}
+
+// Search returns the entries whose prompt or response match query, newest
+// first. The query uses the SQLite FTS5 syntax; for example 'go AND generics'
+// or '"exact phrase"'. If limit is positive, at most limit entries are
+// returned.
+func (db *DB) Search(query string, limit int) ([]*Entry, error) {
+	return db.queryEntries(selectEntries+
+		" WHERE id IN (SELECT rowid FROM entries_fts WHERE entries_fts MATCH ?) ORDER BY id DESC LIMIT ?",
+		query, limitArg(limit))
+}
+
+// UsageRecord is the part of an entry that usage reports need.
+type UsageRecord struct {
+	ID      int64
+	Time    time.Time
+	Command string
+	Model   string
+	Usage   Usage
+}
+
+// UsageBetween returns the usage records of the entries logged in the time
+// range [since, until), oldest first. A zero since or until leaves the range
+// open on that side.
+func (db *DB) UsageBetween(since, until time.Time) ([]*UsageRecord, error) {
+	query := `SELECT id, time, command, model, prompt_tokens, response_tokens, cached_tokens, total_tokens
+	FROM entries`
+	var conds []string
+	var args []any
+	if !since.IsZero() {
+		conds = append(conds, "time >= ?")
+		args = append(args, since.UTC().Format(timeFormat))
+	}
+	if !until.IsZero() {
+		conds = append(conds, "time < ?")
+		args = append(args, until.UTC().Format(timeFormat))
+	}
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY time, id"
+
+	rows, err := db.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []*UsageRecord
+	for rows.Next() {
+		r := &UsageRecord{}
+		var t string
+		err := rows.Scan(&r.ID, &t, &r.Command, &r.Model,
+			&r.Usage.PromptTokens, &r.Usage.ResponseTokens, &r.Usage.CachedTokens, &r.Usage.TotalTokens)
+		if err != nil {
+			return nil, err
+		}
+		if r.Time, err = time.Parse(timeFormat, t); err != nil {
+			return nil, fmt.Errorf("entry %d: %w", r.ID, err)
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// Get returns the entry with the given id.
+func (db *DB) Get(id int64) (*Entry, error) {
+	entries, err := db.queryEntries(selectEntries+" WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w: %d", ErrNotFound, id)
+	}
+	return entries[0], nil
+}
+
+// Attachment returns the attachment with the given hash.
+func (db *DB) Attachment(sha256 string) (*Attachment, error) {
+	a := &Attachment{SHA256: sha256}
+	err := db.db.QueryRow("SELECT mime_type, data FROM attachments WHERE sha256 = ?", sha256).Scan(&a.MIMEType, &a.Data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("attachment %s not found", sha256)
+	} else if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (db *DB) queryEntries(query string, args ...any) ([]*Entry, error) {
+	rows, err := db.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*Entry
+	for rows.Next() {
+		e := &Entry{}
+		var t, parts string
+		var conversation, system, history, finishReason sql.NullString
+		var duration int64
+		err := rows.Scan(&e.ID, &t, &e.Command, &conversation, &e.Model, &system, &history, &parts, &e.Response, &finishReason,
+			&e.Usage.PromptTokens, &e.Usage.ResponseTokens, &e.Usage.CachedTokens, &e.Usage.TotalTokens,
+			&duration)
+		if err != nil {
+			return nil, err
+		}
+		e.Conversation = conversation.String
+		e.System = system.String
+		e.FinishReason = finishReason.String
+		e.Duration = time.Duration(duration)
+		// RFC3339Nano parses timeFormat too.
+		if e.Time, err = time.Parse(time.RFC3339Nano, t); err != nil {
+			return nil, fmt.Errorf("entry %d: %w", e.ID, err)
+		}
+		if err := json.Unmarshal([]byte(parts), &e.Parts); err != nil {
+			return nil, fmt.Errorf("entry %d: %w", e.ID, err)
+		}
+		if history.Valid {
+			if err := json.Unmarshal([]byte(history.String), &e.History); err != nil {
+				return nil, fmt.Errorf("entry %d: %w", e.ID, err)
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// limitArg converts a limit to the argument of a SQL LIMIT clause, where -1
+// means no limit.
+func limitArg(limit int) int {
+	if limit <= 0 {
+		return -1
+	}
+	return limit
+}
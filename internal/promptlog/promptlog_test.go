@@ -0,0 +1,210 @@
+package promptlog
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func openTestDB(t *testing.T) *DB {
+	db, err := Open(filepath.Join(t.TempDir(), "sub", FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestAddAndGet(t *testing.T) {
+	db := openTestDB(t)
+
+	img := NewAttachment("image/png", []byte("not really a png"))
+	e := &Entry{
+		Time:    time.Date(2024, 7, 30, 10, 0, 0, 0, time.UTC),
+		Command: "prompt",
+		Model:   "gemini-1.5-flash",
+		System:  "answer in one sentence",
+		History: []Turn{
+			{Role: "user", Parts: []Part{{Type: "text", Text: "what's a tabby?"}}},
+			{Role: "model", Parts: []Part{{Type: "text", Text: "a kind of cat"}}},
+		},
+		Parts:        []Part{{Type: "text", Text: "describe this image"}, img.Part()},
+		Response:     "it's a cat",
+		FinishReason: "STOP",
+		Usage:        Usage{PromptTokens: 260, ResponseTokens: 4, TotalTokens: 264},
+		Duration:     1500 * time.Millisecond,
+	}
+	if err := db.Add(e, []*Attachment{img}); err != nil {
+		t.Fatal(err)
+	}
+	if e.ID != 1 {
+		t.Errorf("got ID %d, want 1", e.ID)
+	}
+
+	// The same attachment in another entry is stored once.
+	e2 := &Entry{Time: e.Time, Command: "prompt", Model: "m", Parts: []Part{img.Part()}}
+	if err := db.Add(e2, []*Attachment{img}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := db.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(e, got); diff != "" {
+		t.Errorf("entry mismatch (-want +got):\n%s", diff)
+	}
+
+	a, err := db.Attachment(img.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(img, a); diff != "" {
+		t.Errorf("attachment mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := db.Get(42); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v, want ErrNotFound", err)
+	}
+}
+
+func TestListAndSearch(t *testing.T) {
+	db := openTestDB(t)
+
+	texts := [][2]string{
+		{"what is the capital of France?", "Paris"},
+		{"write a haiku about Go generics", "type parameters / ..."},
+		{"what is the capital of Spain?", "Madrid"},
+	}
+	for _, tt := range texts {
+		e := &Entry{
+			Time:     time.Now(),
+			Command:  "prompt",
+			Model:    "m",
+			Parts:    []Part{{Type: "text", Text: tt[0]}},
+			Response: tt[1],
+		}
+		if err := db.Add(e, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids := func(entries []*Entry) []int64 {
+		var ids []int64
+		for _, e := range entries {
+			ids = append(ids, e.ID)
+		}
+		return ids
+	}
+
+	entries, err := db.List(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]int64{3, 2, 1}, ids(entries)); diff != "" {
+		t.Errorf("List mismatch (-want +got):\n%s", diff)
+	}
+
+	entries, err = db.List(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]int64{3, 2}, ids(entries)); diff != "" {
+		t.Errorf("List mismatch (-want +got):\n%s", diff)
+	}
+
+	var searchTests = []struct {
+		query string
+		want  []int64
+	}{
+		{"capital", []int64{3, 1}},
+		{"madrid", []int64{3}},
+		{"generics AND haiku", []int64{2}},
+		{`"capital of spain"`, []int64{3}},
+		{"london", nil},
+	}
+	for _, tt := range searchTests {
+		entries, err := db.Search(tt.query, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(tt.want, ids(entries)); diff != "" {
+			t.Errorf("Search(%q) mismatch (-want +got):\n%s", tt.query, diff)
+		}
+	}
+}
+
+func TestUsageBetween(t *testing.T) {
+	db := openTestDB(t)
+
+	base := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+	for _, d := range []time.Duration{0, 500 * time.Millisecond, 24 * time.Hour, 48*time.Hour + time.Nanosecond} {
+		e := &Entry{Time: base.Add(d), Command: "prompt", Model: "m", Usage: Usage{PromptTokens: 10, TotalTokens: 10}}
+		if err := db.Add(e, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var tests = []struct {
+		name         string
+		since, until time.Time
+		want         []int64
+	}{
+		{"all", time.Time{}, time.Time{}, []int64{1, 2, 3, 4}},
+		{"first day", base, base.Add(24 * time.Hour), []int64{1, 2}},
+		{"within a second", base.Add(time.Millisecond), base.Add(time.Second), []int64{2}},
+		{"since", base.Add(24 * time.Hour), time.Time{}, []int64{3, 4}},
+		{"until", time.Time{}, base.Add(48 * time.Hour), []int64{1, 2, 3}},
+		{"none", base.Add(72 * time.Hour), time.Time{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := db.UsageBetween(tt.since, tt.until)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []int64
+			for _, r := range records {
+				ids = append(ids, r.ID)
+				if r.Usage.PromptTokens != 10 || r.Model != "m" || r.Command != "prompt" {
+					t.Errorf("got record %+v", r)
+				}
+			}
+			if diff := cmp.Diff(tt.want, ids); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTimesSort(t *testing.T) {
+	db := openTestDB(t)
+
+	// With RFC 3339 times, "...00:00.5Z" would sort before "...00:00Z".
+	base := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+	for _, tm := range []time.Time{base.Add(500 * time.Millisecond), base, base.Add(-time.Nanosecond)} {
+		if err := db.Add(&Entry{Time: tm, Command: "prompt", Model: "m"}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rows, err := db.db.Query("SELECT id FROM entries ORDER BY time")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if diff := cmp.Diff([]int64{3, 2, 1}, ids); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,149 @@
+// Package respcache implements a local cache of model responses, stored in a
+// SQLite database.
+//
+// The cache maps keys (hashes of everything that determines a response -
+// computed by the caller) to opaque response data. It doesn't expire entries
+// on its own; they're kept until the cache is cleared.
+package respcache
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/eliben/gemini-cli/internal/config"
+	_ "modernc.org/sqlite"
+)
+
+// FileName is the name of the cache database inside [config.DataDir].
+const FileName = "cache.db"
+
+// Cache is a response cache.
+type Cache struct {
+	db *sql.DB
+}
+
+// Stats are statistics about the contents of a cache.
+type Stats struct {
+	Entries int64
+	Bytes   int64
+
+	// Hits is the total number of times cached responses were used.
+	Hits int64
+
+	// Oldest and Newest are the times the oldest and newest entries were
+	// added; they're zero if the cache is empty.
+	Oldest time.Time
+	Newest time.Time
+}
+
+const schema = `
+CREATE TABLE IF NOT EXISTS responses (
+	key TEXT PRIMARY KEY,
+	model TEXT NOT NULL,
+	data BLOB NOT NULL,
+	created INTEGER NOT NULL, -- Unix time in nanoseconds
+	hits INTEGER NOT NULL DEFAULT 0
+);
+`
+
+// DefaultPath returns the path of the cache database in [config.DataDir].
+func DefaultPath() (string, error) {
+	dir, err := config.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, FileName), nil
+}
+
+// Open opens the cache database at path, creating it if it doesn't exist.
+func Open(path string) (*Cache, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// Concurrent gemini-cli processes may use the cache at the same time.
+	if _, err := db.Exec("PRAGMA busy_timeout = 5000"); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Cache{db: db}, nil
+}
+
+// Close closes the cache.
+func (c *Cache) Close() error {
+	return c.db.Close()
+}
+
+// Get returns the data cached for key, and whether it was found. A found
+// entry is counted as a hit.
+func (c *Cache) Get(key string) ([]byte, bool, error) {
+	var data []byte
+	err := c.db.QueryRow("SELECT data FROM responses WHERE key = ?", key).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	if _, err := c.db.Exec("UPDATE responses SET hits = hits + 1 WHERE key = ?", key); err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// Put caches data for key; model is the name of the model that generated
+// the response.
+func (c *Cache) Put(key string, model string, data []byte) error {
+	return c.put(key, model, data, time.Now())
+}
+
+// put is like Put, with the time the entry was created.
+func (c *Cache) put(key string, model string, data []byte, created time.Time) error {
+	_, err := c.db.Exec("INSERT OR REPLACE INTO responses (key, model, data, created) VALUES (?, ?, ?, ?)",
+		key, model, data, created.UnixNano())
+	return err
+}
+
+// Stats returns statistics about the contents of the cache.
+func (c *Cache) Stats() (*Stats, error) {
+	st := &Stats{}
+	var oldest, newest sql.NullInt64
+	err := c.db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(LENGTH(data)), 0), COALESCE(SUM(hits), 0), MIN(created), MAX(created)
+	FROM responses`).Scan(&st.Entries, &st.Bytes, &st.Hits, &oldest, &newest)
+	if err != nil {
+		return nil, err
+	}
+	if oldest.Valid {
+		st.Oldest = time.Unix(0, oldest.Int64)
+	}
+	if newest.Valid {
+		st.Newest = time.Unix(0, newest.Int64)
+	}
+	return st, nil
+}
+
+// Clear removes all the entries from the cache, and returns the number of
+// entries removed.
+func (c *Cache) Clear() (int64, error) {
+	res, err := c.db.Exec("DELETE FROM responses")
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	// Give the space back to the filesystem.
+	_, err = c.db.Exec("VACUUM")
+	return n, err
+}
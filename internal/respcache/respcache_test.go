@@ -0,0 +1,90 @@
+package respcache
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	c, err := Open(filepath.Join(t.TempDir(), "sub", FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	st, err := c.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Entries != 0 || !st.Oldest.IsZero() {
+		t.Errorf("got stats %+v for empty cache", st)
+	}
+
+	if _, found, err := c.Get("k1"); err != nil || found {
+		t.Fatalf("Get on empty cache: found=%v, err=%v", found, err)
+	}
+
+	if err := c.Put("k1", "model", []byte("response 1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Put("k2", "model", []byte("response 2")); err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		data, found, err := c.Get("k1")
+		if err != nil || !found {
+			t.Fatalf("Get: found=%v, err=%v", found, err)
+		}
+		if string(data) != "response 1" {
+			t.Errorf("got data %q, want %q", data, "response 1")
+		}
+	}
+
+	st, err = c.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Entries != 2 || st.Bytes != 20 || st.Hits != 2 {
+		t.Errorf("got stats %+v, want 2 entries, 20 bytes and 2 hits", st)
+	}
+	if st.Oldest.IsZero() || st.Newest.Before(st.Oldest) {
+		t.Errorf("got oldest %v, newest %v", st.Oldest, st.Newest)
+	}
+
+	n, err := c.Clear()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("Clear removed %d entries, want 2", n)
+	}
+	if _, found, _ := c.Get("k1"); found {
+		t.Error("found entry after Clear")
+	}
+}
+
+func TestStatsTimes(t *testing.T) {
+	c, err := Open(filepath.Join(t.TempDir(), FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// Entries created within the same second, added out of order.
+	base := time.Date(2024, 9, 1, 10, 0, 0, 0, time.UTC)
+	for i, d := range []time.Duration{500 * time.Millisecond, 0, 999 * time.Millisecond} {
+		if err := c.put(string(rune('a'+i)), "model", []byte("response"), base.Add(d)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	st, err := c.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !st.Oldest.Equal(base) || !st.Newest.Equal(base.Add(999*time.Millisecond)) {
+		t.Errorf("got oldest %v, newest %v; want %v, %v", st.Oldest, st.Newest, base, base.Add(999*time.Millisecond))
+	}
+}
@@ -0,0 +1,168 @@
+// Package retry implements an HTTP transport that retries requests failing
+// with transient errors, like rate limiting (HTTP 429) or unavailable servers
+// (HTTP 503).
+//
+// Retries are delayed with exponential backoff and jitter, unless the server
+// says how long to wait, with a Retry-After header or with the RetryInfo
+// details of Google API errors.
+package retry
+
+import (
+	"bytes"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// DefaultBaseDelay is the delay before the first retry of a [Transport]
+// without a BaseDelay.
+const DefaultBaseDelay = time.Second
+
+// maxInspectedBody is the maximal size of error response bodies inspected
+// for retry delays.
+const maxInspectedBody = 64 << 10
+
+// Transport is an [http.RoundTripper] retrying requests that fail with
+// transient errors (see [Retryable]).
+//
+// Requests with a body are only retried if they have a GetBody function to
+// get a fresh copy of it, which [http.NewRequest] sets for common body
+// types.
+type Transport struct {
+	// Base is the transport sending the requests; if nil,
+	// [http.DefaultTransport] is used.
+	Base http.RoundTripper
+
+	// MaxRetries is the maximal number of times a request is retried.
+	MaxRetries int
+
+	// MaxWait is the maximal time to wait before a retry. Backoff delays are
+	// capped at MaxWait, and a request isn't retried if the server asks to
+	// wait longer than that. Zero means no limit.
+	MaxWait time.Duration
+
+	// BaseDelay is the delay before the first retry, which is doubled for
+	// every following retry (before adding jitter). Zero means
+	// DefaultBaseDelay.
+	BaseDelay time.Duration
+
+	// OnRetry, if not nil, is called before waiting to retry a request,
+	// with the response it failed with, the number of the retry (starting
+	// at 1) and the time to wait.
+	OnRetry func(resp *http.Response, retry int, wait time.Duration)
+}
+
+// Retryable reports whether a response with the given HTTP status code is a
+// transient error, for which a request should be retried.
+func Retryable(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	canRetry := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	r := req
+	for retry := 1; ; retry++ {
+		resp, err := base.RoundTrip(r)
+		if err != nil || !Retryable(resp.StatusCode) || !canRetry || retry > t.MaxRetries {
+			return resp, err
+		}
+
+		// The body of the response is read to find the delay it asks for, and
+		// restored in case the request isn't retried.
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxInspectedBody))
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+
+		wait, ok := t.delay(retry, resp.Header, body)
+		if !ok {
+			return resp, nil
+		}
+		if t.OnRetry != nil {
+			t.OnRetry(resp, retry, wait)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		r = req.Clone(req.Context())
+		if req.GetBody != nil {
+			if r.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// delay returns the time to wait before the given retry of a request that
+// failed with a response with the given header and body. It reports false
+// if the request shouldn't be retried, because the server asks to wait
+// longer than t.MaxWait.
+func (t *Transport) delay(retry int, header http.Header, body []byte) (time.Duration, bool) {
+	if d, ok := serverDelay(header, body); ok {
+		return d, t.MaxWait <= 0 || d <= t.MaxWait
+	}
+
+	d := t.BaseDelay
+	if d <= 0 {
+		d = DefaultBaseDelay
+	}
+	// Shifts by more than 30 would overflow for any sensible base delay.
+	d <<= min(retry-1, 30)
+	if t.MaxWait > 0 && d > t.MaxWait {
+		d = t.MaxWait
+	}
+	// Equal jitter: wait at least half the delay, so that retries don't come
+	// too fast, and a random part of the other half, so that clients failing
+	// together don't retry together.
+	half := d / 2
+	return half + rand.N(d-half+1), true
+}
+
+// retryDelayRe matches the retry delay in the RetryInfo details of a Google
+// API error, like "retryDelay": "37s".
+var retryDelayRe = regexp.MustCompile(`"retryDelay"\s*:\s*"(\d+(?:\.\d+)?)s"`)
+
+// serverDelay returns the delay the server asks for before retrying, if
+// any, from the Retry-After header (in seconds or as a date) or from the
+// RetryInfo details of a Google API error in body.
+func serverDelay(header http.Header, body []byte) (time.Duration, bool) {
+	if v := header.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+			return time.Duration(secs) * time.Second, true
+		}
+		if at, err := http.ParseTime(v); err == nil {
+			return max(time.Until(at), 0), true
+		}
+	}
+	if m := retryDelayRe.FindSubmatch(body); m != nil {
+		secs, err := strconv.ParseFloat(string(m[1]), 64)
+		if err == nil {
+			return time.Duration(secs * float64(time.Second)), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,173 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestServer returns a server responding to its requests with the given
+// status codes in order, and then with 200. It records the bodies of the
+// requests it got.
+func newTestServer(t *testing.T, codes []int, header http.Header, body string) (*httptest.Server, *[]string) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		if len(bodies) <= len(codes) {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(codes[len(bodies)-1])
+			io.WriteString(w, body)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &bodies
+}
+
+func post(t *testing.T, client *http.Client, url string) *http.Response {
+	resp, err := client.Post(url, "text/plain", strings.NewReader("request body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestRetries(t *testing.T) {
+	var tests = []struct {
+		name       string
+		codes      []int
+		maxRetries int
+		wantCode   int
+		wantTries  int
+	}{
+		{"success", nil, 3, 200, 1},
+		{"transient", []int{429, 503}, 3, 200, 3},
+		{"all 5xx", []int{500, 502, 503, 504}, 4, 200, 5},
+		{"too many", []int{503, 503, 503}, 2, 503, 3},
+		{"no retries", []int{429}, 0, 429, 1},
+		{"permanent", []int{400}, 3, 400, 1},
+		{"not implemented", []int{501}, 3, 501, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, bodies := newTestServer(t, tt.codes, nil, "error")
+			var retries []int
+			client := &http.Client{Transport: &Transport{
+				MaxRetries: tt.maxRetries,
+				BaseDelay:  time.Millisecond,
+				OnRetry: func(resp *http.Response, retry int, wait time.Duration) {
+					retries = append(retries, retry)
+				},
+			}}
+
+			resp := post(t, client, srv.URL)
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.wantCode)
+			}
+			if len(*bodies) != tt.wantTries {
+				t.Errorf("got %d tries, want %d", len(*bodies), tt.wantTries)
+			}
+			for _, b := range *bodies {
+				if b != "request body" {
+					t.Errorf("got request body %q, want the original body", b)
+				}
+			}
+			if len(retries) != tt.wantTries-1 {
+				t.Errorf("got retries %v, want %d", retries, tt.wantTries-1)
+			}
+
+			// The body of a failed response is kept when giving up.
+			b, _ := io.ReadAll(resp.Body)
+			if want := map[bool]string{true: "ok", false: "error"}[tt.wantCode == 200]; string(b) != want {
+				t.Errorf("got response body %q, want %q", b, want)
+			}
+		})
+	}
+}
+
+func TestServerDelayTooLong(t *testing.T) {
+	header := http.Header{"Retry-After": {"120"}}
+	srv, bodies := newTestServer(t, []int{429}, header, "")
+	client := &http.Client{Transport: &Transport{MaxRetries: 3, MaxWait: time.Minute}}
+
+	if resp := post(t, client, srv.URL); resp.StatusCode != 429 {
+		t.Errorf("got status %d, want 429", resp.StatusCode)
+	}
+	if len(*bodies) != 1 {
+		t.Errorf("got %d tries, want 1", len(*bodies))
+	}
+}
+
+func TestCancel(t *testing.T) {
+	srv, _ := newTestServer(t, []int{503}, nil, "")
+	client := &http.Client{Transport: &Transport{MaxRetries: 3, BaseDelay: time.Hour}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want deadline exceeded", err)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	tr := &Transport{BaseDelay: time.Second, MaxWait: 10 * time.Second}
+	var tests = []struct {
+		retry    int
+		min, max time.Duration
+	}{
+		{1, 500 * time.Millisecond, time.Second},
+		{2, time.Second, 2 * time.Second},
+		{3, 2 * time.Second, 4 * time.Second},
+		{5, 5 * time.Second, 10 * time.Second},
+		{100, 5 * time.Second, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		for range 20 {
+			d, ok := tr.delay(tt.retry, http.Header{}, nil)
+			if !ok || d < tt.min || d > tt.max {
+				t.Errorf("retry %d: got delay %v, %v; want between %v and %v", tt.retry, d, ok, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestServerDelay(t *testing.T) {
+	var tests = []struct {
+		name   string
+		header http.Header
+		body   string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"none", http.Header{}, "", 0, false},
+		{"seconds", http.Header{"Retry-After": {"7"}}, "", 7 * time.Second, true},
+		{"past date", http.Header{"Retry-After": {"Wed, 21 Oct 2015 07:28:00 GMT"}}, "", 0, true},
+		{"invalid", http.Header{"Retry-After": {"soon"}}, "", 0, false},
+		{"retry info", http.Header{}, `{"error": {"details": [{"@type": "type.googleapis.com/google.rpc.RetryInfo", "retryDelay": "37s"}]}}`, 37 * time.Second, true},
+		{"fractional retry info", http.Header{}, `{"retryDelay":"1.5s"}`, 1500 * time.Millisecond, true},
+		{"header first", http.Header{"Retry-After": {"2"}}, `{"retryDelay": "37s"}`, 2 * time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := serverDelay(tt.header, []byte(tt.body))
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("got %v, %v; want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+// Package templates manages named prompt templates, stored as YAML files in
+// the templates directory of the gemini-cli configuration directory.
+//
+// A template holds a prompt, and optionally a system prompt, a model name,
+// a temperature and a JSON Schema for the response. The prompt and system
+// prompt are Go text/template templates, rendered with a map of variables;
+// for example:
+//
+//	description: Summarize a file in a given language
+//	model: gemini-1.5-pro
+//	temperature: 0.2
+//	system: You are a helpful assistant that answers in {{.lang}}.
+//	prompt: |
+//	  Summarize the following text in at most {{.sentences}} sentences.
+package templates
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/eliben/gemini-cli/internal/config"
+	"github.com/eliben/gemini-cli/internal/jsonschema"
+	"gopkg.in/yaml.v3"
+)
+
+// Template is a prompt template.
+type Template struct {
+	Description string         `yaml:"description,omitempty"`
+	System      string         `yaml:"system,omitempty"`
+	Model       string         `yaml:"model,omitempty"`
+	Temperature *float32       `yaml:"temperature,omitempty"`
+	Schema      map[string]any `yaml:"schema,omitempty"`
+	Prompt      string         `yaml:"prompt"`
+}
+
+// fileExt is the extension of template files in the templates directory.
+const fileExt = ".yaml"
+
+var nameRe = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// ErrNotFound is returned when a template with the requested name doesn't
+// exist.
+var ErrNotFound = errors.New("template not found")
+
+// Dir returns the directory holding the templates.
+func Dir() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "templates"), nil
+}
+
+// Path returns the path of the file holding the template with the given name.
+func Path(name string) (string, error) {
+	if !nameRe.MatchString(name) {
+		return "", fmt.Errorf("invalid template name %q; expect letters, digits, '_' and '-'", name)
+	}
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+fileExt), nil
+}
+
+// List returns the names of all the stored templates, sorted.
+func List() ([]string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if name, found := strings.CutSuffix(e.Name(), fileExt); found && !e.IsDir() {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// Load loads the template with the given name.
+func Load(name string) (*Template, error) {
+	path, err := Path(name)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	} else if err != nil {
+		return nil, err
+	}
+
+	t, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("template %s: %w", name, err)
+	}
+	return t, nil
+}
+
+// Parse parses a template from its YAML representation, and checks that
+// its fields are valid.
+func Parse(data []byte) (*Template, error) {
+	t := &Template{}
+	if err := yaml.Unmarshal(data, t); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(t.Prompt) == "" && strings.TrimSpace(t.System) == "" {
+		return nil, errors.New("expect 'prompt' or 'system' to be set")
+	}
+	if _, err := parseText("system", t.System); err != nil {
+		return nil, err
+	}
+	if _, err := parseText("prompt", t.Prompt); err != nil {
+		return nil, err
+	}
+	if _, err := t.ResponseSchema(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Delete deletes the template with the given name.
+func Delete(name string) error {
+	path, err := Path(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return err
+}
+
+// Render renders the system prompt and prompt of t with the given variables.
+// Referring to a variable that isn't in vars is an error.
+func (t *Template) Render(vars map[string]string) (system string, prompt string, err error) {
+	system, err = renderText("system", t.System, vars)
+	if err != nil {
+		return "", "", err
+	}
+	prompt, err = renderText("prompt", t.Prompt, vars)
+	if err != nil {
+		return "", "", err
+	}
+	return system, prompt, nil
+}
+
+// ResponseSchema returns the parsed response schema of t, or nil if t has
+// no schema.
+func (t *Template) ResponseSchema() (*jsonschema.Schema, error) {
+	if t.Schema == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(t.Schema)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := jsonschema.Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("schema: %w", err)
+	}
+	return schema, nil
+}
+
+func parseText(name, text string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=error").Parse(text)
+}
+
+func renderText(name, text string, vars map[string]string) (string, error) {
+	tmpl, err := parseText(name, text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,131 @@
+package templates
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var summarizeTemplate = `
+description: Summarize text
+model: gemini-1.5-pro
+temperature: 0.2
+system: Answer in {{.lang}}.
+prompt: |
+  Summarize in {{.n}} sentences:
+schema:
+  type: object
+  properties:
+    summary: {type: string}
+`
+
+func TestParseAndRender(t *testing.T) {
+	tmpl, err := Parse([]byte(summarizeTemplate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.Model != "gemini-1.5-pro" || tmpl.Temperature == nil || *tmpl.Temperature != 0.2 {
+		t.Errorf("got model %q temperature %v", tmpl.Model, tmpl.Temperature)
+	}
+
+	system, prompt, err := tmpl.Render(map[string]string{"lang": "Spanish", "n": "3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if system != "Answer in Spanish." {
+		t.Errorf("got system %q", system)
+	}
+	if prompt != "Summarize in 3 sentences:\n" {
+		t.Errorf("got prompt %q", prompt)
+	}
+
+	schema, err := tmpl.ResponseSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema == nil || len(schema.Properties) != 1 {
+		t.Errorf("unexpected schema %+v", schema)
+	}
+
+	_, _, err = tmpl.Render(map[string]string{"lang": "Spanish"})
+	if err == nil || !strings.Contains(err.Error(), `"n"`) {
+		t.Errorf("got error %v, want missing variable error", err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	var tests = []struct {
+		data    string
+		wantErr string
+	}{
+		{`model: foo`, `expect 'prompt' or 'system'`},
+		{`prompt: "{{.x"`, `unclosed action`},
+		{`prompt: hi
+schema: {type: object, oneOf: []}`, `unsupported schema keyword`},
+		{`prompt: [1, 2`, `yaml`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStore(t *testing.T) {
+	t.Setenv("GEMINI_CLI_CONFIG_DIR", t.TempDir())
+
+	names, err := List()
+	if err != nil || len(names) != 0 {
+		t.Fatalf("got names %v, err %v; want none", names, err)
+	}
+
+	for _, name := range []string{"summarize", "a-b_c"} {
+		path, err := Path(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(strings.TrimSuffix(path, name+fileExt), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(summarizeTemplate), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names, err = List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"a-b_c", "summarize"}, names); diff != "" {
+		t.Errorf("names mismatch (-want +got):\n%s", diff)
+	}
+
+	tmpl, err := Load("summarize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.Description != "Summarize text" {
+		t.Errorf("got description %q", tmpl.Description)
+	}
+
+	if err := Delete("summarize"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load("summarize"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v, want ErrNotFound", err)
+	}
+	if err := Delete("summarize"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v, want ErrNotFound", err)
+	}
+
+	if _, err := Path("../etc/passwd"); err == nil {
+		t.Error("expected error for invalid name")
+	}
+}
@@ -0,0 +1,290 @@
+// Package tools loads declarations of local tools that a model can call
+// (also known as function calling), and invokes these tools.
+//
+// Tools are declared in a YAML file with a list of tools, each describing a
+// function with a name, description and JSON Schema for its parameters. A
+// tool is implemented either by a local command or by an HTTP endpoint:
+//
+//	tools:
+//	  - name: get_weather
+//	    description: Get the current weather in a given city
+//	    parameters:
+//	      type: object
+//	      properties:
+//	        city: {type: string}
+//	      required: [city]
+//	    command: [./weather.sh]
+//	  - name: find_user
+//	    description: Find a user in the directory by name
+//	    parameters:
+//	      type: object
+//	      properties:
+//	        name: {type: string}
+//	    http:
+//	      url: https://directory.example.com/api/find
+//	      headers:
+//	        Authorization: Bearer ${DIRECTORY_TOKEN}
+//
+// Commands receive the call's arguments as a JSON object on standard input;
+// HTTP endpoints receive them as a JSON request body (for POST, the default)
+// or as query parameters (for GET). The output of a command, or the body of
+// an HTTP response, is the result of the call; if it's a JSON object it's
+// returned as is, otherwise it's wrapped in an object as {"output": ...}.
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/eliben/gemini-cli/internal/jsonschema"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultTimeout is the time a tool call may take, unless the tool's
+// declaration sets a different timeout.
+const DefaultTimeout = 60 * time.Second
+
+// Tool is a declaration of a single tool.
+type Tool struct {
+	Name        string         `yaml:"name"`
+	Description string         `yaml:"description"`
+	Parameters  map[string]any `yaml:"parameters"`
+
+	// Command is the command line of a command implementing the tool.
+	Command []string `yaml:"command"`
+
+	// HTTP is the endpoint implementing the tool.
+	HTTP *HTTPEndpoint `yaml:"http"`
+
+	Timeout time.Duration `yaml:"timeout"`
+
+	// Schema is the parsed Parameters; it's nil for tools without parameters.
+	Schema *jsonschema.Schema `yaml:"-"`
+}
+
+// HTTPEndpoint is an HTTP endpoint implementing a tool.
+type HTTPEndpoint struct {
+	URL string `yaml:"url"`
+
+	// Method is either "POST" (the default) or "GET".
+	Method string `yaml:"method"`
+
+	// Headers are added to the request. Environment variable references like
+	// $VAR or ${VAR} in their values are expanded.
+	Headers map[string]string `yaml:"headers"`
+}
+
+type toolsFile struct {
+	Tools []*Tool `yaml:"tools"`
+}
+
+// nameRe matches valid function names, per the Gemini API.
+var nameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]{0,62}$`)
+
+// LoadFile loads tool declarations from the YAML file at path. Relative
+// command paths (like ./tool.sh) are resolved relative to the file's
+// directory.
+func LoadFile(path string) ([]*Tool, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tools, err := Load(b)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	for _, t := range tools {
+		if len(t.Command) > 0 && strings.ContainsRune(t.Command[0], filepath.Separator) && !filepath.IsAbs(t.Command[0]) {
+			t.Command[0] = filepath.Join(dir, t.Command[0])
+		}
+	}
+	return tools, nil
+}
+
+// Load loads tool declarations from YAML data.
+func Load(data []byte) ([]*Tool, error) {
+	var tf toolsFile
+	if err := yaml.Unmarshal(data, &tf); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for i, t := range tf.Tools {
+		if !nameRe.MatchString(t.Name) {
+			return nil, fmt.Errorf("tool #%d: invalid name %q", i+1, t.Name)
+		}
+		if seen[t.Name] {
+			return nil, fmt.Errorf("tool %s: declared more than once", t.Name)
+		}
+		seen[t.Name] = true
+
+		if (len(t.Command) > 0) == (t.HTTP != nil) {
+			return nil, fmt.Errorf("tool %s: expect exactly one of 'command' or 'http'", t.Name)
+		}
+		if t.HTTP != nil {
+			switch t.HTTP.Method {
+			case "":
+				t.HTTP.Method = http.MethodPost
+			case http.MethodPost, http.MethodGet:
+			default:
+				return nil, fmt.Errorf("tool %s: unsupported HTTP method %q", t.Name, t.HTTP.Method)
+			}
+		}
+		if t.Timeout == 0 {
+			t.Timeout = DefaultTimeout
+		}
+
+		if t.Parameters != nil {
+			// The schema parser expects JSON; the YAML-decoded parameters map
+			// converts cleanly.
+			b, err := json.Marshal(t.Parameters)
+			if err != nil {
+				return nil, fmt.Errorf("tool %s: %w", t.Name, err)
+			}
+			schema, err := jsonschema.Parse(b)
+			if err != nil {
+				return nil, fmt.Errorf("tool %s: parameters: %w", t.Name, err)
+			}
+			if schema.Type != jsonschema.TypeObject {
+				return nil, fmt.Errorf("tool %s: expect parameters of type object", t.Name)
+			}
+			t.Schema = schema
+		}
+	}
+	return tf.Tools, nil
+}
+
+// Call invokes the tool with the given arguments, returning the result.
+// client is used for tools implemented by HTTP endpoints; if it's nil,
+// [http.DefaultClient] is used.
+func (t *Tool) Call(ctx context.Context, client *http.Client, args map[string]any) (map[string]any, error) {
+	if args == nil {
+		args = map[string]any{}
+	}
+	if t.Schema != nil {
+		if err := t.Schema.Validate(args); err != nil {
+			return nil, fmt.Errorf("invalid arguments: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.Timeout)
+	defer cancel()
+
+	var out []byte
+	var err error
+	if t.HTTP != nil {
+		out, err = t.callHTTP(ctx, client, args)
+	} else {
+		out, err = t.callCommand(ctx, args)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeResult(out), nil
+}
+
+func (t *Tool) callCommand(ctx context.Context, args map[string]any) ([]byte, error) {
+	input, err := json.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, t.Command[0], t.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+func (t *Tool) callHTTP(ctx context.Context, client *http.Client, args map[string]any) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := t.newHTTPRequest(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range t.HTTP.Headers {
+		req.Header.Set(k, os.ExpandEnv(v))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, errors.New(strings.TrimSpace(fmt.Sprintf("%s: %s", resp.Status, body)))
+	}
+	return body, nil
+}
+
+// newHTTPRequest creates the request for calling the tool's HTTP endpoint
+// with args.
+func (t *Tool) newHTTPRequest(ctx context.Context, args map[string]any) (*http.Request, error) {
+	if t.HTTP.Method == http.MethodGet {
+		u, err := url.Parse(t.HTTP.URL)
+		if err != nil {
+			return nil, err
+		}
+		q := u.Query()
+		for k, v := range args {
+			if s, ok := v.(string); ok {
+				q.Set(k, s)
+			} else {
+				b, _ := json.Marshal(v)
+				q.Set(k, string(b))
+			}
+		}
+		u.RawQuery = q.Encode()
+		return http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	}
+
+	body, err := json.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.HTTP.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// decodeResult decodes the output of a tool call into a result object.
+func decodeResult(out []byte) map[string]any {
+	var obj map[string]any
+	if err := json.Unmarshal(out, &obj); err == nil && obj != nil {
+		return obj
+	}
+	return map[string]any{"output": strings.TrimSpace(string(out))}
+}
@@ -0,0 +1,185 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var sampleTools = `
+tools:
+  - name: get_weather
+    description: Get the weather
+    parameters:
+      type: object
+      properties:
+        city: {type: string}
+        days: {type: integer, minimum: 1}
+      required: [city]
+    command: [cat]
+  - name: find_user
+    description: Find a user
+    http:
+      url: http://example.com/find
+      method: GET
+    timeout: 5s
+`
+
+func TestLoad(t *testing.T) {
+	tools, err := Load([]byte(sampleTools))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tools) != 2 {
+		t.Fatalf("got %d tools, want 2", len(tools))
+	}
+
+	weather := tools[0]
+	if weather.Name != "get_weather" || weather.Timeout != DefaultTimeout {
+		t.Errorf("got name %q timeout %v", weather.Name, weather.Timeout)
+	}
+	if weather.Schema == nil || len(weather.Schema.Properties) != 2 {
+		t.Errorf("unexpected schema %+v", weather.Schema)
+	}
+
+	user := tools[1]
+	if user.Schema != nil || user.HTTP.Method != "GET" || user.Timeout != 5*time.Second {
+		t.Errorf("unexpected tool %+v", user)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	var tests = []struct {
+		data    string
+		wantErr string
+	}{
+		{`tools: [{name: "bad name", command: [ls]}]`, `invalid name "bad name"`},
+		{`tools: [{name: a, command: [ls]}, {name: a, command: [ls]}]`, `declared more than once`},
+		{`tools: [{name: a}]`, `exactly one of 'command' or 'http'`},
+		{`tools: [{name: a, command: [ls], http: {url: "http://x"}}]`, `exactly one of 'command' or 'http'`},
+		{`tools: [{name: a, http: {url: "http://x", method: PUT}}]`, `unsupported HTTP method`},
+		{`tools: [{name: a, command: [ls], parameters: {type: string}}]`, `expect parameters of type object`},
+		{`tools: [{name: a, command: [ls], parameters: {type: object, anyOf: []}}]`, `unsupported schema keyword`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			_, err := Load([]byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadFileResolvesCommand(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tools.yaml")
+	data := "tools: [{name: a, command: [./a.sh, x]}, {name: b, command: [ls]}]"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tools, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tools[0].Command, []string{filepath.Join(dir, "a.sh"), "x"}; !cmp.Equal(got, want) {
+		t.Errorf("got command %v, want %v", got, want)
+	}
+	if got, want := tools[1].Command, []string{"ls"}; !cmp.Equal(got, want) {
+		t.Errorf("got command %v, want %v", got, want)
+	}
+}
+
+func TestCallCommand(t *testing.T) {
+	tools, err := Load([]byte(sampleTools))
+	if err != nil {
+		t.Fatal(err)
+	}
+	weather := tools[0]
+
+	// 'cat' echoes the JSON arguments back, so they're the result.
+	args := map[string]any{"city": "Paris", "days": float64(2)}
+	result, err := weather.Call(context.Background(), nil, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(args, result); diff != "" {
+		t.Errorf("result mismatch (-want +got):\n%s", diff)
+	}
+
+	_, err = weather.Call(context.Background(), nil, map[string]any{"days": float64(2)})
+	if err == nil || !strings.Contains(err.Error(), "invalid arguments") {
+		t.Errorf("got error %v, want invalid arguments", err)
+	}
+}
+
+func TestCallCommandTextAndErrors(t *testing.T) {
+	text := &Tool{Name: "t", Command: []string{"echo", "hello there"}, Timeout: DefaultTimeout}
+	result, err := text.Call(context.Background(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[string]any{"output": "hello there"}, result); diff != "" {
+		t.Errorf("result mismatch (-want +got):\n%s", diff)
+	}
+
+	failing := &Tool{Name: "f", Command: []string{"sh", "-c", "echo oops >&2; exit 3"}, Timeout: DefaultTimeout}
+	_, err = failing.Call(context.Background(), nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "oops") {
+		t.Errorf("got error %v, want it to contain stderr", err)
+	}
+}
+
+func TestCallHTTP(t *testing.T) {
+	t.Setenv("TOOLS_TEST_TOKEN", "sekrit")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sekrit" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodGet {
+			json.NewEncoder(w).Encode(map[string]any{"name": r.URL.Query().Get("name")})
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	headers := map[string]string{"Authorization": "Bearer ${TOOLS_TEST_TOKEN}"}
+	get := &Tool{Name: "g", HTTP: &HTTPEndpoint{URL: ts.URL, Method: "GET", Headers: headers}, Timeout: DefaultTimeout}
+	result, err := get.Call(context.Background(), nil, map[string]any{"name": "joe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[string]any{"name": "joe"}, result); diff != "" {
+		t.Errorf("result mismatch (-want +got):\n%s", diff)
+	}
+
+	post := &Tool{Name: "p", HTTP: &HTTPEndpoint{URL: ts.URL, Method: "POST", Headers: headers}, Timeout: DefaultTimeout}
+	result, err = post.Call(context.Background(), ts.Client(), map[string]any{"id": "x1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[string]any{"id": "x1"}, result); diff != "" {
+		t.Errorf("result mismatch (-want +got):\n%s", diff)
+	}
+
+	noauth := &Tool{Name: "n", HTTP: &HTTPEndpoint{URL: ts.URL, Method: "POST"}, Timeout: DefaultTimeout}
+	_, err = noauth.Call(context.Background(), nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("got error %v, want 401", err)
+	}
+}
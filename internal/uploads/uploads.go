@@ -0,0 +1,145 @@
+// Package uploads tracks the files gemini-cli uploaded with the Gemini File
+// API, so that the same content isn't uploaded again while the uploaded file
+// is still alive.
+//
+// Uploads are recorded in a SQLite database, keyed by the SHA-256 hash of
+// their content.
+package uploads
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/eliben/gemini-cli/internal/config"
+	_ "modernc.org/sqlite"
+)
+
+// FileName is the name of the uploads database inside [config.DataDir].
+const FileName = "uploads.db"
+
+// Upload is a record of an uploaded file.
+type Upload struct {
+	// SHA256 is the hex-encoded hash of the file's content.
+	SHA256 string
+
+	// Name is the name of the file in the File API, like "files/abc-123".
+	Name     string
+	URI      string
+	MIMEType string
+	Size     int64
+
+	// Expires is the time the File API deletes the file.
+	Expires time.Time
+}
+
+// Tracker is a database of uploads.
+type Tracker struct {
+	db *sql.DB
+}
+
+const schema = `
+CREATE TABLE IF NOT EXISTS uploads (
+	sha256 TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	uri TEXT NOT NULL,
+	mime_type TEXT NOT NULL,
+	size INTEGER NOT NULL,
+	expires TEXT NOT NULL
+);
+`
+
+// DefaultPath returns the path of the uploads database in [config.DataDir].
+func DefaultPath() (string, error) {
+	dir, err := config.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, FileName), nil
+}
+
+// Open opens the uploads database at path, creating it if it doesn't exist.
+func Open(path string) (*Tracker, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec("PRAGMA busy_timeout = 5000"); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Tracker{db: db}, nil
+}
+
+// Close closes the database.
+func (t *Tracker) Close() error {
+	return t.db.Close()
+}
+
+// Hash returns the hex-encoded SHA-256 hash of data, which is the key of
+// uploads in the tracker.
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// HashReader is like [Hash] for the content read from r, which is hashed as
+// it's read rather than held in memory. It also returns the size of the
+// content.
+func HashReader(r io.Reader) (string, int64, error) {
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return "", n, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// Lookup returns the upload of the content with the given hash, if there's
+// one that doesn't expire before minTTL passes. It returns nil if there's no
+// such upload.
+func (t *Tracker) Lookup(sha256 string, minTTL time.Duration) (*Upload, error) {
+	u := &Upload{SHA256: sha256}
+	var expires string
+	err := t.db.QueryRow("SELECT name, uri, mime_type, size, expires FROM uploads WHERE sha256 = ?", sha256).
+		Scan(&u.Name, &u.URI, &u.MIMEType, &u.Size, &expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	u.Expires, err = time.Parse(time.RFC3339, expires)
+	if err != nil {
+		return nil, err
+	}
+	if time.Until(u.Expires) < minTTL {
+		return nil, nil
+	}
+	return u, nil
+}
+
+// Add records u, replacing any earlier upload of the same content.
+func (t *Tracker) Add(u *Upload) error {
+	_, err := t.db.Exec("INSERT OR REPLACE INTO uploads VALUES (?, ?, ?, ?, ?, ?)",
+		u.SHA256, u.Name, u.URI, u.MIMEType, u.Size, u.Expires.UTC().Format(time.RFC3339))
+	return err
+}
+
+// Remove removes the records of uploads with the given File API name.
+func (t *Tracker) Remove(name string) error {
+	_, err := t.db.Exec("DELETE FROM uploads WHERE name = ?", name)
+	return err
+}
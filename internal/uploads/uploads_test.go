@@ -0,0 +1,70 @@
+package uploads
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTracker(t *testing.T) {
+	tr, err := Open(filepath.Join(t.TempDir(), "sub", FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+
+	hash := Hash([]byte("some video"))
+	if u, err := tr.Lookup(hash, 0); err != nil || u != nil {
+		t.Fatalf("Lookup on empty tracker: got %v, %v", u, err)
+	}
+
+	u := &Upload{
+		SHA256:   hash,
+		Name:     "files/abc-123",
+		URI:      "https://example.com/files/abc-123",
+		MIMEType: "video/mp4",
+		Size:     10,
+		Expires:  time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second),
+	}
+	if err := tr.Add(u); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := tr.Lookup(hash, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(u, got); diff != "" {
+		t.Errorf("upload mismatch (-want +got):\n%s", diff)
+	}
+
+	// Uploads that expire too soon aren't returned.
+	if got, err := tr.Lookup(hash, 49*time.Hour); err != nil || got != nil {
+		t.Errorf("got %v, %v for upload expiring too soon", got, err)
+	}
+
+	if err := tr.Remove("files/abc-123"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := tr.Lookup(hash, 0); err != nil || got != nil {
+		t.Errorf("got %v, %v after Remove", got, err)
+	}
+}
+
+func TestHashReader(t *testing.T) {
+	data := []byte(strings.Repeat("some video", 10000))
+	hash, n, err := HashReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := Hash(data); hash != want {
+		t.Errorf("got hash %s, want %s", hash, want)
+	}
+	if n != int64(len(data)) {
+		t.Errorf("got size %d, want %d", n, len(data))
+	}
+}
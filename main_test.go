@@ -3,6 +3,8 @@ package main_test
 import (
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/eliben/gemini-cli/internal/commands"
@@ -31,13 +33,51 @@ func TestScript(t *testing.T) {
 			// Propagate the test env's GEMINI_API_KEY to scripts.
 			env.Setenv("GEMINI_API_KEY", os.Getenv("GEMINI_API_KEY"))
 
+			// Keep the data gemini-cli stores between runs (like its log) in
+			// the test's work directory, and don't let the user's config file
+			// (with its defaults for flags) affect tests. Scripts testing the
+			// config file set GEMINI_CLI_CONFIG_DIR themselves.
+			env.Setenv("GEMINI_CLI_DATA_DIR", filepath.Join(env.WorkDir, ".gemini-cli-data"))
+			env.Setenv("GEMINI_CLI_CONFIG_DIR", filepath.Join(env.WorkDir, ".gemini-cli-config"))
+
 			// This is to help testing some error scenarios.
 			env.Setenv("TEST_API_KEY", os.Getenv("GEMINI_API_KEY"))
 			return nil
 		},
+		Cmds: map[string]func(ts *testscript.TestScript, neg bool, args []string){
+			"repeat":     cmdRepeat,
+			"stdout2env": cmdStdoutToEnv,
+		},
 	})
 }
 
+// cmdRepeat implements the 'repeat <n> <src> <dst>' command of test
+// scripts, which writes the content of src repeated n times to dst (for
+// tests that need large inputs).
+func cmdRepeat(ts *testscript.TestScript, neg bool, args []string) {
+	if neg || len(args) != 3 {
+		ts.Fatalf("usage: repeat <n> <src> <dst>")
+	}
+	n, err := strconv.Atoi(args[0])
+	ts.Check(err)
+	ts.Check(os.WriteFile(ts.MkAbs(args[2]), []byte(strings.Repeat(ts.ReadFile(args[1]), n)), 0666))
+}
+
+// cmdStdoutToEnv implements the 'stdout2env <name>' command of test
+// scripts, which sets the environment variable name to the first field of
+// the standard output of the last command (like the name of something it
+// created).
+func cmdStdoutToEnv(ts *testscript.TestScript, neg bool, args []string) {
+	if neg || len(args) != 1 {
+		ts.Fatalf("usage: stdout2env <name>")
+	}
+	fields := strings.Fields(ts.ReadFile("stdout"))
+	if len(fields) == 0 {
+		ts.Fatalf("no output to set %s from", args[0])
+	}
+	ts.Setenv(args[0], fields[0])
+}
+
 func check(t *testing.T, err error) {
 	if err != nil {
 		t.Fatal(err)